@@ -0,0 +1,9 @@
+// Package api provides the schemas of the messages produced by kube2kafka.
+package api
+
+import _ "embed"
+
+// EventProto is the protobuf schema of the event exported in the protobuf output format.
+//
+//go:embed proto/kube2kafka/v1/event.proto
+var EventProto string
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/circular"
+	"time"
+)
+
+type BufferConfig struct {
+	// Size overrides the bufferSize for the particular buffer.
+	Size int `yaml:"size"`
+	// MaxBytes is the byte budget of the buffer, which limits the total size of the
+	// stored data in addition to its size. If not set, only the size is limited.
+	MaxBytes  int    `yaml:"maxBytes"`
+	RawPolicy string `yaml:"policy" default:"overwrite-oldest"`
+	// Timeout is the maximum time the writer waits for the free space when
+	// the block policy is used.
+	Timeout time.Duration `yaml:"timeout" default:"5s"`
+}
+
+func (c *BufferConfig) Validate() error {
+	if c.Size < 0 {
+		return fmt.Errorf("size must not be negative")
+	}
+
+	if c.MaxBytes < 0 {
+		return fmt.Errorf("max bytes must not be negative")
+	}
+
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+
+	if _, err := c.GetPolicy(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *BufferConfig) GetPolicy() (circular.OverflowPolicy, error) {
+	if c.RawPolicy == "" {
+		return circular.OverwriteOldestPolicy, nil
+	}
+
+	policy, err := circular.MapOverflowPolicyString(c.RawPolicy)
+	if err != nil {
+		return "", fmt.Errorf("failed to map overflow policy string: %w", err)
+	}
+	return policy, nil
+}
+
+// BuffersConfig allows to configure each of the buffers connecting the components.
+type BuffersConfig struct {
+	// Events is the buffer between the watcher and the processor.
+	Events *BufferConfig `yaml:"events"`
+	// Messages is the buffer between the processor and the exporter.
+	Messages *BufferConfig `yaml:"messages"`
+}
+
+func (c *BuffersConfig) Validate() error {
+	if c.Events != nil {
+		if err := c.Events.Validate(); err != nil {
+			return fmt.Errorf("events buffer has issues: %w", err)
+		}
+	}
+
+	if c.Messages != nil {
+		if err := c.Messages.Validate(); err != nil {
+			return fmt.Errorf("messages buffer has issues: %w", err)
+		}
+	}
+	return nil
+}
+
+// bufferSpec returns the capacity and the options of the buffer, where the sizeOf is used
+// to account the values in the byte budget. If the buffer is not configured, the default
+// size and the overwrite-oldest policy are used.
+func bufferSpec[T any](
+	c *BufferConfig,
+	size int,
+	sizeOf circular.SizeFunc[T],
+) (int, []circular.Option[T], error) {
+	if c == nil {
+		return size, nil, nil
+	}
+
+	if c.Size > 0 {
+		size = c.Size
+	}
+
+	policy, err := c.GetPolicy()
+	if err != nil {
+		return 0, nil, err
+	}
+	opts := []circular.Option[T]{circular.WithOverflowPolicy[T](policy, c.Timeout)}
+	if c.MaxBytes > 0 {
+		opts = append(opts, circular.WithByteBudget(c.MaxBytes, sizeOf))
+	}
+	return size, opts, nil
+}
@@ -11,13 +11,21 @@ import (
 )
 
 type Config struct {
-	ClusterName     string               `yaml:"clusterName"`
-	TargetNamespace string               `yaml:"namespace"`
-	MaxEventAge     time.Duration        `yaml:"maxEventAge"`
-	BufferSize      int                  `yaml:"bufferSize"`
-	Kafka           *KafkaConfig         `yaml:"kafka"`
-	Filters         []processor.Filter   `yaml:"filters"`
-	Selectors       []processor.Selector `yaml:"selectors"`
+	ClusterName     string                     `yaml:"clusterName"`
+	TargetNamespace string                     `yaml:"namespace"`
+	MaxEventAge     time.Duration              `yaml:"maxEventAge"`
+	BufferSize      int                        `yaml:"bufferSize"`
+	Buffers         *BuffersConfig             `yaml:"buffers"`
+	Kafka           *KafkaConfig               `yaml:"kafka"`
+	Severity        *processor.SeverityMapping `yaml:"severity"`
+	Filters         []processor.Filter         `yaml:"filters"`
+	Selectors       []processor.Selector       `yaml:"selectors"`
+	Transform       *processor.Transform       `yaml:"transform"`
+	Redaction       []processor.RedactionRule  `yaml:"redaction"`
+	Output          *OutputConfig              `yaml:"output"`
+	Spool           *SpoolConfig               `yaml:"spool"`
+	DeadLetter      *DeadLetterConfig          `yaml:"deadLetter"`
+	Metrics         *MetricsConfig             `yaml:"metrics"`
 }
 
 func (c *Config) SetDefaults() {
@@ -39,6 +47,12 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("buffer size must be positive")
 	}
 
+	if c.Buffers != nil {
+		if err := c.Buffers.Validate(); err != nil {
+			return fmt.Errorf("buffers config has issues: %w", err)
+		}
+	}
+
 	if c.Kafka == nil {
 		return fmt.Errorf("kafka config is required")
 	}
@@ -47,6 +61,14 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("kafka config has issues: %w", err)
 	}
 
+	if c.Severity != nil {
+		if err := c.Severity.Validate(); err != nil {
+			return fmt.Errorf("severity has issues: %w", err)
+		}
+	} else if c.matchesSeverity() {
+		return fmt.Errorf("filters matching severity require severity mapping")
+	}
+
 	for i, filter := range c.Filters {
 		err := filter.Validate()
 		if err != nil {
@@ -60,9 +82,102 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("selector at index %d has issues: %w", i, err)
 		}
 	}
+
+	if err := processor.ValidateSelectors(c.Selectors); err != nil {
+		return fmt.Errorf("selectors have issues: %w", err)
+	}
+
+	if err := processor.ValidateRedactionRules(c.Redaction); err != nil {
+		return fmt.Errorf("redaction has issues: %w", err)
+	}
+
+	if c.Transform != nil {
+		if err := c.Transform.Validate(); err != nil {
+			return fmt.Errorf("transform has issues: %w", err)
+		}
+	}
+
+	if c.Spool != nil {
+		if err := c.Spool.Validate(); err != nil {
+			return fmt.Errorf("spool config has issues: %w", err)
+		}
+
+		// Without acks, the spooled messages would be acknowledged before the broker
+		// confirms them, thus the rejected ones would be lost.
+		if c.Kafka.Producer != nil && c.Kafka.Producer.RawRequiredAcks == "none" {
+			return fmt.Errorf("required acks must not be none when spool is set")
+		}
+	}
+
+	if c.DeadLetter != nil {
+		if err := c.DeadLetter.Validate(); err != nil {
+			return fmt.Errorf("dead letter config has issues: %w", err)
+		}
+
+		if c.DeadLetter.Kafka != nil && c.DeadLetter.Kafka.Topic == c.Kafka.Topic {
+			return fmt.Errorf("dead letter topic must differ from the default topic")
+		}
+
+		if c.DeadLetter.Kafka != nil {
+			err := c.DeadLetter.Kafka.ValidateMaxMessageBytes(c.Kafka.GetMaxMessageBytes())
+			if err != nil {
+				return fmt.Errorf("dead letter config has issues: %w", err)
+			}
+		}
+	}
+
+	if c.Metrics != nil {
+		if err := c.Metrics.Validate(); err != nil {
+			return fmt.Errorf("metrics config has issues: %w", err)
+		}
+	}
+
+	if c.Output != nil {
+		if err := c.Output.Validate(); err != nil {
+			return fmt.Errorf("output config has issues: %w", err)
+		}
+
+		if err := c.Output.ValidatePayload(c.Selectors, c.Transform); err != nil {
+			return fmt.Errorf("output config has issues: %w", err)
+		}
+
+		if err := c.Output.ValidateRoutes(c.Kafka.Routes); err != nil {
+			return fmt.Errorf("output config has issues: %w", err)
+		}
+
+		if c.Output.expectsSchema() && c.Kafka.GapMarkers != nil && *c.Kafka.GapMarkers {
+			return fmt.Errorf("gap markers are not supported by %s format", c.Output.Format)
+		}
+	}
 	return nil
 }
 
+// GetGapMarkers returns whether the gap markers are sent. They are disabled by default
+// for the formats expecting the schema, as the markers are always plain JSON.
+func (c *Config) GetGapMarkers() bool {
+	if c.Kafka.GapMarkers != nil {
+		return *c.Kafka.GapMarkers
+	}
+	return c.Output == nil || !c.Output.expectsSchema()
+}
+
+// matchesSeverity checks whether any of the filters, including those of the routes,
+// matches the severity of the events.
+func (c *Config) matchesSeverity() bool {
+	for _, filter := range c.Filters {
+		if filter.Severity != "" {
+			return true
+		}
+	}
+
+	for _, route := range c.Kafka.Routes {
+		if route.Filter.Severity != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Config) GetCluster() kube.Cluster {
 	return kube.Cluster{
 		Name:            c.ClusterName,
@@ -70,6 +185,34 @@ func (c *Config) GetCluster() kube.Cluster {
 	}
 }
 
+// GetEventBuffer creates the buffer between the watcher and the processor.
+func (c *Config) GetEventBuffer() (*watcher.EventBuffer, error) {
+	var config *BufferConfig
+	if c.Buffers != nil {
+		config = c.Buffers.Events
+	}
+
+	size, opts, err := bufferSpec(config, c.BufferSize, watcher.EventSize)
+	if err != nil {
+		return nil, err
+	}
+	return watcher.NewEventBuffer(size, opts...), nil
+}
+
+// GetMessageBuffer creates the buffer between the processor and the exporter.
+func (c *Config) GetMessageBuffer() (*processor.KafkaMessageBuffer, error) {
+	var config *BufferConfig
+	if c.Buffers != nil {
+		config = c.Buffers.Messages
+	}
+
+	size, opts, err := bufferSpec(config, c.BufferSize, processor.MessageSize)
+	if err != nil {
+		return nil, err
+	}
+	return processor.NewKafkaMessageBuffer(size, opts...), nil
+}
+
 func Read(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/deadletter"
+	"github.com/raczu/kube2kafka/pkg/exporter"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+	"net"
+	"time"
+)
+
+type DeadLetterKafkaConfig struct {
+	// Topic is the dead-letter topic on the same brokers as the default topic.
+	Topic string `yaml:"topic"`
+	// MaxMessageBytes is the maximum size of the record. Records are larger than the
+	// messages they carry, thus it defaults to the size of the record carrying the message
+	// of the max message bytes of the kafka config and must not be lower. Larger records,
+	// e.g. of the oversized messages, are truncated.
+	MaxMessageBytes int `yaml:"maxMessageBytes"`
+}
+
+func (c *DeadLetterKafkaConfig) Validate() error {
+	if c.Topic == "" {
+		return fmt.Errorf("topic is required")
+	}
+
+	if err := processor.ValidateTopicName(c.Topic); err != nil {
+		return fmt.Errorf("topic has issues: %w", err)
+	}
+
+	if c.MaxMessageBytes < 0 {
+		return fmt.Errorf("max message bytes must not be negative")
+	}
+	return nil
+}
+
+// ValidateMaxMessageBytes checks that the records of the messages of the given maximum
+// size fit in the maximum size of the record.
+func (c *DeadLetterKafkaConfig) ValidateMaxMessageBytes(messageBytes int) error {
+	n := deadletter.MaxRecordBytes(messageBytes)
+	if c.MaxMessageBytes > 0 && c.MaxMessageBytes < n {
+		return fmt.Errorf(
+			"max message bytes must not be lower than %d to fit the records of the messages "+
+				"of kafka max message bytes (%d)", n, messageBytes,
+		)
+	}
+	return nil
+}
+
+// GetMaxMessageBytes returns the maximum size of the record, which defaults to the size
+// of the record carrying the message of the given maximum size.
+func (c *DeadLetterKafkaConfig) GetMaxMessageBytes(messageBytes int) int {
+	if c.MaxMessageBytes == 0 {
+		return deadletter.MaxRecordBytes(messageBytes)
+	}
+	return c.MaxMessageBytes
+}
+
+type DeadLetterFileConfig struct {
+	// Path is the path of the JSONL file, which should be placed on the persistent
+	// volume for the records to survive the pod restarts.
+	Path       string `yaml:"path"`
+	MaxBytes   int64  `yaml:"maxBytes" default:"67108864"`
+	MaxBackups *int   `yaml:"maxBackups" default:"3"`
+}
+
+func (c *DeadLetterFileConfig) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+
+	if c.MaxBytes < 0 {
+		return fmt.Errorf("max bytes must not be negative")
+	}
+
+	if c.MaxBackups != nil && *c.MaxBackups < 0 {
+		return fmt.Errorf("max backups must not be negative")
+	}
+	return nil
+}
+
+func (c *DeadLetterFileConfig) GetMaxBytes() int64 {
+	if c.MaxBytes == 0 {
+		return deadletter.DefaultMaxFileBytes
+	}
+	return c.MaxBytes
+}
+
+func (c *DeadLetterFileConfig) GetMaxBackups() int {
+	if c.MaxBackups == nil {
+		return deadletter.DefaultMaxBackups
+	}
+	return *c.MaxBackups
+}
+
+// DeadLetterConfig defines the destination of the messages which cannot be delivered,
+// which is either the Kafka topic or the local file.
+type DeadLetterConfig struct {
+	Kafka *DeadLetterKafkaConfig `yaml:"kafka"`
+	File  *DeadLetterFileConfig  `yaml:"file"`
+}
+
+func (c *DeadLetterConfig) Validate() error {
+	if (c.Kafka == nil) == (c.File == nil) {
+		return fmt.Errorf("exactly one of kafka or file is required")
+	}
+
+	if c.Kafka != nil {
+		if err := c.Kafka.Validate(); err != nil {
+			return fmt.Errorf("kafka config has issues: %w", err)
+		}
+	}
+
+	if c.File != nil {
+		if err := c.File.Validate(); err != nil {
+			return fmt.Errorf("file config has issues: %w", err)
+		}
+	}
+	return nil
+}
+
+// Open opens the dead-letter sink. The Kafka sink uses the brokers and the security
+// settings of the given kafka config.
+func (c *DeadLetterConfig) Open(
+	config *KafkaConfig,
+	logger *zap.Logger,
+) (*deadletter.DeadLetter, error) {
+	var sink deadletter.Sink
+	if c.Kafka != nil {
+		tls, err := config.GetTLS()
+		if err != nil {
+			return nil, err
+		}
+
+		mechanism, err := config.GetSASL()
+		if err != nil {
+			return nil, err
+		}
+
+		// The timeouts of the producer apply to the records as well, whereas the unset
+		// ones keep the defaults of the exporter and the writer.
+		dialTimeout := exporter.DefaultDialTimeout
+		var readTimeout, writeTimeout time.Duration
+		if producer := config.Producer; producer != nil {
+			if producer.DialTimeout > 0 {
+				dialTimeout = producer.DialTimeout
+			}
+			readTimeout, writeTimeout = producer.ReadTimeout, producer.WriteTimeout
+		}
+
+		writer := &kafka.Writer{
+			Addr:  kafka.TCP(config.Brokers...),
+			Topic: c.Kafka.Topic,
+			// Records of the same message key land on the same partition.
+			Balancer:     &kafka.Hash{},
+			BatchBytes:   int64(c.Kafka.GetMaxMessageBytes(config.GetMaxMessageBytes())),
+			ReadTimeout:  readTimeout,
+			WriteTimeout: writeTimeout,
+			Transport: &kafka.Transport{
+				Dial: (&net.Dialer{
+					Timeout: dialTimeout,
+				}).DialContext,
+				TLS:  tls,
+				SASL: mechanism,
+			},
+		}
+		sink = deadletter.NewKafkaSink(writer, writeTimeout)
+	} else {
+		var err error
+		sink, err = deadletter.NewFileSink(
+			c.File.Path,
+			c.File.GetMaxBytes(),
+			c.File.GetMaxBackups(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open dead-letter sink: %w", err)
+		}
+	}
+
+	return deadletter.New(
+		sink,
+		deadletter.WithDefaultTopic(config.Topic),
+		deadletter.WithLogger(logger),
+	), nil
+}
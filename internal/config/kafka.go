@@ -4,9 +4,13 @@ import (
 	"crypto/tls"
 	"fmt"
 	"github.com/raczu/kube2kafka/pkg/exporter"
+	"github.com/raczu/kube2kafka/pkg/processor"
 	"github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl"
 	"os"
+	"slices"
+	"strings"
+	"time"
 )
 
 type RawTLSData struct {
@@ -70,12 +74,181 @@ func (r *RawSASLData) Build() (sasl.Mechanism, error) {
 	return factory(r.Username, r.Password)
 }
 
+type RetryConfig struct {
+	// MaxRetries is the number of retries of the message which failed to be written,
+	// after which the message is dropped. Zero disables the retries.
+	MaxRetries     *int          `yaml:"maxRetries" default:"5"`
+	InitialBackoff time.Duration `yaml:"initialBackoff" default:"100ms"`
+	MaxBackoff     time.Duration `yaml:"maxBackoff" default:"10s"`
+	// MaxQueued is the maximum number of messages waiting for the retry, beyond which
+	// the oldest ones are dropped.
+	MaxQueued int `yaml:"maxQueued" default:"1024"`
+}
+
+func (r *RetryConfig) Validate() error {
+	if r.MaxRetries != nil && *r.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative")
+	}
+
+	if r.InitialBackoff < 0 || r.MaxBackoff < 0 {
+		return fmt.Errorf("backoff must not be negative")
+	}
+
+	if r.MaxQueued < 0 {
+		return fmt.Errorf("max queued must not be negative")
+	}
+
+	policy := r.GetPolicy()
+	if policy.InitialBackoff > policy.MaxBackoff {
+		return fmt.Errorf("initial backoff must not exceed max backoff")
+	}
+	return nil
+}
+
+func (r *RetryConfig) GetPolicy() exporter.RetryPolicy {
+	policy := exporter.DefaultRetryPolicy()
+	if r.MaxRetries != nil {
+		policy.MaxRetries = *r.MaxRetries
+	}
+
+	if r.InitialBackoff > 0 {
+		policy.InitialBackoff = r.InitialBackoff
+	}
+
+	if r.MaxBackoff > 0 {
+		policy.MaxBackoff = r.MaxBackoff
+	}
+
+	if r.MaxQueued > 0 {
+		policy.MaxQueued = r.MaxQueued
+	}
+	return policy
+}
+
+// ProducerConfig tunes the writer sending the messages to Kafka, e.g. for lower latency
+// or higher throughput. Unset options keep the defaults of the exporter.
+type ProducerConfig struct {
+	BatchSize    int           `yaml:"batchSize" default:"16"`
+	BatchTimeout time.Duration `yaml:"batchTimeout" default:"1s"`
+	// BatchBytes is the maximum size of the batch sent to the partition, which must
+	// not be lower than the max message bytes it defaults to.
+	BatchBytes  int64 `yaml:"batchBytes"`
+	MaxAttempts int   `yaml:"maxAttempts" default:"3"`
+	// RawRequiredAcks defaults to none, or to all when the spool is set.
+	RawRequiredAcks string        `yaml:"requiredAcks"`
+	DialTimeout     time.Duration `yaml:"dialTimeout" default:"5s"`
+	ReadTimeout     time.Duration `yaml:"readTimeout" default:"10s"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout" default:"10s"`
+}
+
+func (c *ProducerConfig) Validate(maxMessageBytes int) error {
+	if c.BatchSize < 0 {
+		return fmt.Errorf("batch size must not be negative")
+	}
+
+	if c.BatchBytes < 0 {
+		return fmt.Errorf("batch bytes must not be negative")
+	}
+
+	if c.BatchBytes > 0 && c.BatchBytes < int64(maxMessageBytes) {
+		return fmt.Errorf(
+			"batch bytes must not be lower than max message bytes (%d)", maxMessageBytes,
+		)
+	}
+
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("max attempts must not be negative")
+	}
+
+	if c.BatchTimeout < 0 || c.DialTimeout < 0 || c.ReadTimeout < 0 || c.WriteTimeout < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+
+	if c.RawRequiredAcks != "" {
+		if _, err := exporter.MapRequiredAcksString(c.RawRequiredAcks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOptions returns the exporter options of the set producer options.
+func (c *ProducerConfig) GetOptions() ([]exporter.Option, error) {
+	var opts []exporter.Option
+	if c.BatchSize > 0 {
+		opts = append(opts, exporter.WithBatchSize(c.BatchSize))
+	}
+
+	if c.BatchTimeout > 0 {
+		opts = append(opts, exporter.WithBatchTimeout(c.BatchTimeout))
+	}
+
+	if c.BatchBytes > 0 {
+		opts = append(opts, exporter.WithBatchBytes(c.BatchBytes))
+	}
+
+	if c.MaxAttempts > 0 {
+		opts = append(opts, exporter.WithMaxAttempts(c.MaxAttempts))
+	}
+
+	if c.RawRequiredAcks != "" {
+		acks, err := exporter.MapRequiredAcksString(c.RawRequiredAcks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to map required acks string: %w", err)
+		}
+		opts = append(opts, exporter.WithRequiredAcks(acks))
+	}
+
+	if c.DialTimeout > 0 {
+		opts = append(opts, exporter.WithDialTimeout(c.DialTimeout))
+	}
+
+	if c.ReadTimeout > 0 || c.WriteTimeout > 0 {
+		opts = append(opts, exporter.WithTimeouts(c.ReadTimeout, c.WriteTimeout))
+	}
+	return opts, nil
+}
+
 type KafkaConfig struct {
-	Brokers        []string     `yaml:"brokers"`
-	Topic          string       `yaml:"topic"`
-	RawCompression string       `yaml:"compression" default:"none"`
-	RawTLS         *RawTLSData  `yaml:"tls"`
-	RawSASL        *RawSASLData `yaml:"sasl"`
+	Brokers []string `yaml:"brokers"`
+	// Topic is the default topic for events not matching any of the routes.
+	Topic  string            `yaml:"topic"`
+	Routes []processor.Route `yaml:"routes"`
+	// Key is the template of the message key. If not set, the event UID is used,
+	// whereas an empty string results in messages without the key.
+	Key *string `yaml:"key"`
+	// Partitioner is either the name of the partitioner or the template of the partition,
+	// e.g. {{ if eq .Type "Warning" }}0{{ else }}1{{ end }}. If not set, the hash
+	// partitioner is used with the custom key and the least-bytes one otherwise.
+	RawPartitioner string             `yaml:"partitioner"`
+	Headers        []processor.Header `yaml:"headers"`
+	RawTimestamp   string             `yaml:"timestamp" default:"send"`
+	// MaxMessageBytes is the maximum size of the message, which should not exceed
+	// the max.message.bytes of the topics. Messages exceeding it are handled
+	// according to the oversize policy.
+	MaxMessageBytes   int    `yaml:"maxMessageBytes" default:"1048576"`
+	RawOversizePolicy string `yaml:"oversizePolicy" default:"dead-letter"`
+	// GapMarkers defines whether the gap markers are sent to the default topic when
+	// the events or messages are lost due to the buffer overflow. They are enabled
+	// by default, unless the output format expects the schema.
+	GapMarkers     *bool           `yaml:"gapMarkers"`
+	Retry          *RetryConfig    `yaml:"retry"`
+	Producer       *ProducerConfig `yaml:"producer"`
+	RawCompression string          `yaml:"compression" default:"none"`
+	RawTLS         *RawTLSData     `yaml:"tls"`
+	RawSASL        *RawSASLData    `yaml:"sasl"`
+}
+
+// GetTopics returns the default topic and the topics of the routes, except for the
+// templated ones, which are known only once the events are routed.
+func (c *KafkaConfig) GetTopics() []string {
+	topics := []string{c.Topic}
+	for _, route := range c.Routes {
+		if !route.IsTemplate() && !slices.Contains(topics, route.Topic) {
+			topics = append(topics, route.Topic)
+		}
+	}
+	return topics
 }
 
 func (c *KafkaConfig) Validate() error {
@@ -87,6 +260,54 @@ func (c *KafkaConfig) Validate() error {
 		return fmt.Errorf("topic is required")
 	}
 
+	if err := processor.ValidateTopicName(c.Topic); err != nil {
+		return fmt.Errorf("topic has issues: %w", err)
+	}
+
+	for i, route := range c.Routes {
+		if err := route.Validate(); err != nil {
+			return fmt.Errorf("route at index %d has issues: %w", i, err)
+		}
+	}
+
+	if c.Key != nil {
+		if err := processor.ValidateTemplate(*c.Key); err != nil {
+			return fmt.Errorf("key has issues: %w", err)
+		}
+	}
+
+	if c.RawPartitioner != "" {
+		if err := c.validatePartitioner(); err != nil {
+			return fmt.Errorf("partitioner has issues: %w", err)
+		}
+	}
+
+	for i, header := range c.Headers {
+		if err := header.Validate(); err != nil {
+			return fmt.Errorf("header at index %d has issues: %w", i, err)
+		}
+	}
+
+	if c.MaxMessageBytes < 0 {
+		return fmt.Errorf("max message bytes must not be negative")
+	}
+
+	if _, err := c.GetOversizePolicy(); err != nil {
+		return err
+	}
+
+	if c.Retry != nil {
+		if err := c.Retry.Validate(); err != nil {
+			return fmt.Errorf("retry config has issues: %w", err)
+		}
+	}
+
+	if c.Producer != nil {
+		if err := c.Producer.Validate(c.GetMaxMessageBytes()); err != nil {
+			return fmt.Errorf("producer config has issues: %w", err)
+		}
+	}
+
 	if c.RawSASL != nil {
 		if err := c.RawSASL.Validate(); err != nil {
 			return fmt.Errorf("sasl config has issues: %w", err)
@@ -109,6 +330,101 @@ func (c *KafkaConfig) GetSASL() (sasl.Mechanism, error) {
 	return c.RawSASL.Build()
 }
 
+func (c *KafkaConfig) GetKey() processor.KeyFunc {
+	if c.Key == nil {
+		return processor.UIDKey
+	}
+	return processor.TemplateKey(*c.Key)
+}
+
+// isPartitionTemplate checks whether the partitioner is the template of the partition
+// rather than the name of the partitioner.
+func (c *KafkaConfig) isPartitionTemplate() bool {
+	return strings.Contains(c.RawPartitioner, "{{")
+}
+
+func (c *KafkaConfig) validatePartitioner() error {
+	if c.isPartitionTemplate() {
+		return processor.ValidateTemplate(c.RawPartitioner)
+	}
+
+	_, err := exporter.MapPartitionerString(c.RawPartitioner)
+	return err
+}
+
+// GetBalancer returns the balancer assigning the messages to the partitions. It returns
+// nil if the partitioner is not set, so the default of the exporter is kept.
+func (c *KafkaConfig) GetBalancer() (kafka.Balancer, error) {
+	if c.RawPartitioner == "" {
+		return nil, nil
+	}
+
+	if c.isPartitionTemplate() {
+		// Messages whose partition failed to be rendered are assigned by the key hash.
+		return &exporter.PartitionBalancer{Fallback: kafka.Murmur2Balancer{}}, nil
+	}
+
+	balancer, err := exporter.MapPartitionerString(c.RawPartitioner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map partitioner string: %w", err)
+	}
+	return balancer, nil
+}
+
+// GetPartition returns the function rendering the partition of the messages, which is
+// set only if the partitioner is the template.
+func (c *KafkaConfig) GetPartition() processor.PartitionFunc {
+	if !c.isPartitionTemplate() {
+		return nil
+	}
+	return processor.TemplatePartition(c.RawPartitioner)
+}
+
+func (c *KafkaConfig) GetTimestamp() (processor.TimestampFunc, error) {
+	if c.RawTimestamp == "" {
+		return processor.SendTimestamp, nil
+	}
+
+	timestamp, err := processor.MapTimestampString(c.RawTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map timestamp string: %w", err)
+	}
+	return timestamp, nil
+}
+
+func (c *KafkaConfig) GetMaxMessageBytes() int {
+	if c.MaxMessageBytes == 0 {
+		return processor.DefaultMaxMessageBytes
+	}
+	return c.MaxMessageBytes
+}
+
+func (c *KafkaConfig) GetOversizePolicy() (processor.OversizePolicy, error) {
+	if c.RawOversizePolicy == "" {
+		return processor.DeadLetterPolicy, nil
+	}
+
+	policy, err := processor.MapOversizePolicyString(c.RawOversizePolicy)
+	if err != nil {
+		return "", fmt.Errorf("failed to map oversize policy string: %w", err)
+	}
+	return policy, nil
+}
+
+func (c *KafkaConfig) GetRetryPolicy() exporter.RetryPolicy {
+	if c.Retry == nil {
+		return exporter.DefaultRetryPolicy()
+	}
+	return c.Retry.GetPolicy()
+}
+
+func (c *KafkaConfig) GetProducerOptions() ([]exporter.Option, error) {
+	if c.Producer == nil {
+		return nil, nil
+	}
+	return c.Producer.GetOptions()
+}
+
 func (c *KafkaConfig) GetCompression() (kafka.Compression, error) {
 	compression, err := exporter.MapCodecString(c.RawCompression)
 	if err != nil {
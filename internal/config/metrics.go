@@ -0,0 +1,23 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+type MetricsConfig struct {
+	// Address is the address of the HTTP server exposing the metrics at /debug/vars,
+	// e.g. :8080.
+	Address string `yaml:"address"`
+}
+
+func (c *MetricsConfig) Validate() error {
+	if c.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return fmt.Errorf("address is not valid: %w", err)
+	}
+	return nil
+}
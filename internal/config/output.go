@@ -0,0 +1,294 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"github.com/raczu/kube2kafka/api"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	"github.com/raczu/kube2kafka/pkg/registry"
+)
+
+const (
+	JSONFormat        = "json"
+	CloudEventsFormat = "cloudevents"
+	AvroFormat        = "avro"
+	ProtobufFormat    = "protobuf"
+	ConnectFormat     = "connect"
+	OTLPFormat        = "otlp"
+)
+
+type CloudEventsConfig struct {
+	RawMode string `yaml:"mode" default:"structured"`
+}
+
+func (c *CloudEventsConfig) GetMode() (processor.CloudEventsMode, error) {
+	if c.RawMode == "" {
+		return processor.StructuredMode, nil
+	}
+	return processor.MapCloudEventsModeString(c.RawMode)
+}
+
+type ProtobufConfig struct {
+	RawFraming string `yaml:"framing" default:"none"`
+}
+
+func (c *ProtobufConfig) GetFraming() (processor.ProtobufFraming, error) {
+	if c.RawFraming == "" {
+		return processor.NoFraming, nil
+	}
+	return processor.MapProtobufFramingString(c.RawFraming)
+}
+
+type RegistryConfig struct {
+	URL string `yaml:"url"`
+	// Subject is the subject under which the schema is registered. If empty, the
+	// schema is registered under the subjects derived from the name of each topic.
+	Subject string `yaml:"subject"`
+	// AutoRegister defines whether the schema should be registered or only
+	// looked up in the registry. It is enabled by default.
+	AutoRegister *bool  `yaml:"autoRegister"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+}
+
+func (c *RegistryConfig) Validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	return nil
+}
+
+// GetSchemaID registers or looks up the schema in the registry and returns its identifier.
+// Without the explicit subject, the schema is registered under the subject of each of the
+// topics the messages are sent to, which must resolve to the same identifier, as it is
+// embedded in every message regardless of the topic.
+func (c *RegistryConfig) GetSchemaID(
+	topics []string,
+	schema string,
+	schemaType registry.SchemaType,
+) (int, error) {
+	var opts []registry.Option
+	if c.Username != "" {
+		opts = append(opts, registry.WithBasicAuth(c.Username, c.Password))
+	}
+	client := registry.NewClient(c.URL, opts...)
+
+	subjects := []string{c.Subject}
+	if c.Subject == "" {
+		subjects = make([]string, 0, len(topics))
+		for _, topic := range topics {
+			subjects = append(subjects, registry.TopicSubject(topic))
+		}
+	}
+
+	id := -1
+	for _, subject := range subjects {
+		sid, err := c.getSubjectSchemaID(client, subject, schema, schemaType)
+		if err != nil {
+			return 0, fmt.Errorf("subject %s: %w", subject, err)
+		}
+
+		if id != -1 && sid != id {
+			return 0, fmt.Errorf(
+				"schema id %d of subject %s differs from id %d of subject %s",
+				sid, subject, id, subjects[0],
+			)
+		}
+		id = sid
+	}
+	return id, nil
+}
+
+func (c *RegistryConfig) getSubjectSchemaID(
+	client *registry.Client,
+	subject, schema string,
+	schemaType registry.SchemaType,
+) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), registry.DefaultTimeout)
+	defer cancel()
+
+	if c.AutoRegister == nil || *c.AutoRegister {
+		return client.Register(ctx, subject, schema, schemaType)
+	}
+	return client.Lookup(ctx, subject, schema, schemaType)
+}
+
+type OutputConfig struct {
+	Format      string             `yaml:"format" default:"json"`
+	CloudEvents *CloudEventsConfig `yaml:"cloudevents"`
+	Protobuf    *ProtobufConfig    `yaml:"protobuf"`
+	Registry    *RegistryConfig    `yaml:"registry"`
+}
+
+// getProtobufFraming returns the framing of the protobuf format, which defaults to none.
+func (c *OutputConfig) getProtobufFraming() (processor.ProtobufFraming, error) {
+	if c.Protobuf == nil {
+		return processor.NoFraming, nil
+	}
+	return c.Protobuf.GetFraming()
+}
+
+func (c *OutputConfig) Validate() error {
+	switch c.Format {
+	case "", JSONFormat, ConnectFormat, OTLPFormat:
+	case CloudEventsFormat:
+		if c.CloudEvents != nil {
+			if _, err := c.CloudEvents.GetMode(); err != nil {
+				return fmt.Errorf("cloudevents config has issues: %w", err)
+			}
+		}
+	case AvroFormat:
+		if err := c.validateRegistry(); err != nil {
+			return err
+		}
+	case ProtobufFormat:
+		framing, err := c.getProtobufFraming()
+		if err != nil {
+			return fmt.Errorf("protobuf config has issues: %w", err)
+		}
+
+		if framing == processor.ConfluentFraming {
+			if err = c.validateRegistry(); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown format: %s", c.Format)
+	}
+	return nil
+}
+
+func (c *OutputConfig) validateRegistry() error {
+	if c.Registry == nil {
+		return fmt.Errorf("registry config is required for %s format", c.Format)
+	}
+
+	if err := c.Registry.Validate(); err != nil {
+		return fmt.Errorf("registry config has issues: %w", err)
+	}
+	return nil
+}
+
+// usesRegistry checks whether the output format requires the schema registry.
+func (c *OutputConfig) usesRegistry() bool {
+	switch c.Format {
+	case AvroFormat:
+		return true
+	case ProtobufFormat:
+		framing, err := c.getProtobufFraming()
+		return err == nil && framing == processor.ConfluentFraming
+	}
+	return false
+}
+
+// expectsSchema checks whether the consumers of the output format expect every message
+// to carry the schema, either embedded or referenced in the registry, thus fail on the
+// plain JSON messages, such as the gap markers.
+func (c *OutputConfig) expectsSchema() bool {
+	return c.Format == ConnectFormat || c.usesRegistry()
+}
+
+// ValidateRoutes checks whether the schema can be registered for the topics of the
+// routes. The subjects cannot be derived from the templated topics, thus the explicit
+// subject is required with them.
+func (c *OutputConfig) ValidateRoutes(routes []processor.Route) error {
+	if !c.usesRegistry() || c.Registry.Subject != "" {
+		return nil
+	}
+
+	for i, route := range routes {
+		if route.IsTemplate() {
+			return fmt.Errorf(
+				"registry subject is required, as the topic of route at index %d is a template",
+				i,
+			)
+		}
+	}
+	return nil
+}
+
+// ValidatePayload checks whether the payload created by the selectors and the transform
+// can be encoded in the output format.
+func (c *OutputConfig) ValidatePayload(
+	selectors []processor.Selector,
+	transform *processor.Transform,
+) error {
+	switch c.Format {
+	case AvroFormat:
+		if transform != nil {
+			return fmt.Errorf("transform is not supported by %s format", c.Format)
+		}
+
+		if _, err := processor.AvroSchema(processor.PayloadFields(selectors)); err != nil {
+			return fmt.Errorf("failed to derive avro schema: %w", err)
+		}
+	case ConnectFormat:
+		// The schema is derived from the selectors, thus the shape of the transformed
+		// payload would not match it.
+		if transform != nil {
+			return fmt.Errorf("transform is not supported by %s format", c.Format)
+		}
+	case ProtobufFormat, OTLPFormat:
+		// The message has a fixed structure built from the whole event.
+		if len(selectors) > 0 || transform != nil {
+			return fmt.Errorf("selectors and transform are not supported by %s format", c.Format)
+		}
+	}
+	return nil
+}
+
+func (c *OutputConfig) GetEncoder(
+	selectors []processor.Selector,
+	topics []string,
+) (processor.Encoder, error) {
+	switch c.Format {
+	case "", JSONFormat:
+		return processor.JSONEncoder{}, nil
+	case CloudEventsFormat:
+		mode := processor.StructuredMode
+		if c.CloudEvents != nil {
+			var err error
+			mode, err = c.CloudEvents.GetMode()
+			if err != nil {
+				return nil, fmt.Errorf("failed to map cloudevents mode string: %w", err)
+			}
+		}
+		return &processor.CloudEventsEncoder{Mode: mode}, nil
+	case AvroFormat:
+		fields := processor.PayloadFields(selectors)
+		schema, err := processor.AvroSchema(fields)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive avro schema: %w", err)
+		}
+
+		id, err := c.Registry.GetSchemaID(topics, schema, registry.Avro)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get avro schema id: %w", err)
+		}
+		return processor.NewAvroEncoder(fields, id)
+	case ConnectFormat:
+		return processor.NewConnectEncoder(processor.PayloadFields(selectors))
+	case OTLPFormat:
+		return processor.OTLPEncoder{}, nil
+	case ProtobufFormat:
+		framing, err := c.getProtobufFraming()
+		if err != nil {
+			return nil, fmt.Errorf("failed to map protobuf framing string: %w", err)
+		}
+
+		encoder := &processor.ProtobufEncoder{Framing: framing}
+		if framing == processor.ConfluentFraming {
+			encoder.SchemaID, err = c.Registry.GetSchemaID(
+				topics,
+				api.EventProto,
+				registry.Protobuf,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get protobuf schema id: %w", err)
+			}
+		}
+		return encoder, nil
+	default:
+		return nil, fmt.Errorf("unknown format: %s", c.Format)
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/spool"
+	"go.uber.org/zap"
+	"time"
+)
+
+type SpoolConfig struct {
+	// Dir is the directory of the spool, which should be placed on the persistent
+	// volume for the messages to survive the pod restarts.
+	Dir          string `yaml:"dir"`
+	SegmentBytes int64  `yaml:"segmentBytes" default:"16777216"`
+	// MaxBytes and MaxAge limit the total size and the age of the spooled messages.
+	// Messages exceeding any of the limits are removed even if not written to Kafka.
+	MaxBytes int64         `yaml:"maxBytes"`
+	MaxAge   time.Duration `yaml:"maxAge"`
+}
+
+func (c *SpoolConfig) Validate() error {
+	if c.Dir == "" {
+		return fmt.Errorf("dir is required")
+	}
+
+	if c.SegmentBytes < 0 {
+		return fmt.Errorf("segment bytes must not be negative")
+	}
+
+	if c.MaxBytes < 0 {
+		return fmt.Errorf("max bytes must not be negative")
+	}
+
+	if c.MaxAge < 0 {
+		return fmt.Errorf("max age must not be negative")
+	}
+	return nil
+}
+
+// Open opens the spool, recovering the messages left by the previous run.
+func (c *SpoolConfig) Open(logger *zap.Logger) (*spool.Spool, error) {
+	opts := []spool.Option{
+		spool.WithRetention(c.MaxBytes, c.MaxAge),
+		spool.WithLogger(logger),
+	}
+
+	if c.SegmentBytes > 0 {
+		opts = append(opts, spool.WithSegmentBytes(c.SegmentBytes))
+	}
+
+	s, err := spool.Open(c.Dir, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %w", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,162 @@
+// Package kafkatest provides the stub of the Kafka broker for the tests of the components
+// writing to Kafka.
+package kafkatest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/produce"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Transport acts as the single broker holding every topic with the single partition.
+// It reads the records of the produce requests, so their encoding is accounted, and
+// counts them instead of sending them anywhere. The records are kept only if Keep is set,
+// whereas the records of the rejected topics are neither read nor counted. The missing
+// topics are reported as unknown in the metadata. The OnProduce is called after each
+// produce request, if set.
+type Transport struct {
+	Keep      bool
+	OnProduce func()
+	produced  atomic.Int64
+	messages  []kafka.Message
+	rejected  map[string]kafka.Error
+	missing   map[string]bool
+	mu        sync.Mutex
+}
+
+func (t *Transport) RoundTrip(
+	_ context.Context,
+	_ net.Addr,
+	req kafka.Request,
+) (kafka.Response, error) {
+	switch req := req.(type) {
+	case *metadata.Request:
+		res := &metadata.Response{
+			Brokers: []metadata.ResponseBroker{{NodeID: 1, Host: "localhost", Port: 9092}},
+		}
+		for _, topic := range req.TopicNames {
+			if t.isMissing(topic) {
+				res.Topics = append(res.Topics, metadata.ResponseTopic{
+					Name:      topic,
+					ErrorCode: int16(kafka.UnknownTopicOrPartition),
+				})
+				continue
+			}
+			res.Topics = append(res.Topics, metadata.ResponseTopic{
+				Name:       topic,
+				Partitions: []metadata.ResponsePartition{{LeaderID: 1}},
+			})
+		}
+		return res, nil
+	case *produce.Request:
+		res := &produce.Response{}
+		for _, topic := range req.Topics {
+			rt := produce.ResponseTopic{Topic: topic.Topic}
+			code := t.rejection(topic.Topic)
+			for _, partition := range topic.Partitions {
+				if code == 0 {
+					err := t.readRecords(topic.Topic, partition.RecordSet.Records)
+					if err != nil {
+						return nil, err
+					}
+				}
+				rt.Partitions = append(rt.Partitions, produce.ResponsePartition{
+					Partition: partition.Partition,
+					ErrorCode: code,
+				})
+			}
+			res.Topics = append(res.Topics, rt)
+		}
+
+		if t.OnProduce != nil {
+			t.OnProduce()
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unexpected request: %T", req)
+	}
+}
+
+func (t *Transport) readRecords(topic string, records kafka.RecordReader) error {
+	for {
+		record, err := records.ReadRecord()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var value []byte
+		if record.Value != nil {
+			if value, err = io.ReadAll(record.Value); err != nil {
+				return err
+			}
+		}
+
+		if t.Keep {
+			t.mu.Lock()
+			t.messages = append(t.messages, kafka.Message{
+				Topic:   topic,
+				Value:   value,
+				Headers: record.Headers,
+			})
+			t.mu.Unlock()
+		}
+		t.produced.Add(1)
+	}
+}
+
+// Reject makes the records of the topic rejected with the error, or accepted again
+// if the error is zero.
+func (t *Transport) Reject(topic string, err kafka.Error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.rejected == nil {
+		t.rejected = make(map[string]kafka.Error)
+	}
+	t.rejected[topic] = err
+}
+
+func (t *Transport) rejection(topic string) int16 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int16(t.rejected[topic])
+}
+
+// Delete makes the topic unknown to the broker.
+func (t *Transport) Delete(topic string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.missing == nil {
+		t.missing = make(map[string]bool)
+	}
+	t.missing[topic] = true
+}
+
+func (t *Transport) isMissing(topic string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.missing[topic]
+}
+
+// Produced returns the number of the produced records.
+func (t *Transport) Produced() int64 {
+	return t.produced.Load()
+}
+
+// Messages returns the copy of the kept messages.
+func (t *Transport) Messages() []kafka.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]kafka.Message(nil), t.messages...)
+}
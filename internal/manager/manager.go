@@ -4,16 +4,43 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"expvar"
+	"fmt"
 	k2kconfig "github.com/raczu/kube2kafka/internal/config"
+	"github.com/raczu/kube2kafka/pkg/deadletter"
 	"github.com/raczu/kube2kafka/pkg/exporter"
 	"github.com/raczu/kube2kafka/pkg/kube/watcher"
 	"github.com/raczu/kube2kafka/pkg/processor"
+	"github.com/raczu/kube2kafka/pkg/spool"
+	"github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl"
 	"go.uber.org/zap"
 	"k8s.io/client-go/rest"
+	"net/http"
 	"sync"
+	"time"
 )
 
+const (
+	// statsInterval is the interval of logging the usage of the buffers.
+	statsInterval = 1 * time.Minute
+	// shutdownTimeout limits the time of serving the pending requests of the metrics
+	// server on shutdown.
+	shutdownTimeout = 5 * time.Second
+)
+
+// stats holds the usage of the buffers and the spool along with the counters of the
+// processor, which is exposed as the kube2kafka variable at /debug/vars of the metrics
+// server along with the runtime stats.
+var stats = expvar.NewMap("kube2kafka")
+
+// bufferUsage is implemented by the buffers connecting the components.
+type bufferUsage interface {
+	Size() int
+	Bytes() int
+	Lost() uint64
+}
+
 var (
 	ErrManagerNotSetup = errors.New("manager was not set up")
 )
@@ -22,6 +49,8 @@ type Manager struct {
 	watcher    *watcher.Watcher
 	processor  *processor.Processor
 	exporter   *exporter.Exporter
+	spool      *spool.Spool
+	deadLetter *deadletter.DeadLetter
 	config     *k2kconfig.Config
 	kubeconfig *rest.Config
 	logger     *zap.Logger
@@ -36,8 +65,20 @@ func New(cfg *k2kconfig.Config, kubeconfig *rest.Config, logger *zap.Logger) *Ma
 	}
 }
 
-func (m *Manager) Setup() error {
-	events := watcher.NewEventBuffer(m.config.BufferSize)
+func (m *Manager) Setup() (err error) {
+	// The manager is not started if the setup fails, thus whatever was opened
+	// so far is closed right away.
+	defer func() {
+		if err != nil {
+			m.close()
+		}
+	}()
+
+	events, err := m.config.GetEventBuffer()
+	if err != nil {
+		return err
+	}
+
 	m.watcher = watcher.New(
 		m.kubeconfig,
 		m.config.GetCluster(),
@@ -46,20 +87,82 @@ func (m *Manager) Setup() error {
 		watcher.WriteTo(events),
 	)
 
-	messages := processor.NewKafkaMessageBuffer(m.config.BufferSize)
+	messages, err := m.config.GetMessageBuffer()
+	if err != nil {
+		return err
+	}
+
 	popts := []processor.Option{
 		processor.WithLogger(m.logger.Named("processor")),
 		processor.WriteTo(messages),
+		processor.WithKey(m.config.Kafka.GetKey()),
+		processor.WithGapMarkers(m.config.GetGapMarkers()),
+	}
+
+	if m.config.DeadLetter != nil {
+		m.deadLetter, err = m.config.DeadLetter.Open(m.config.Kafka, m.logger.Named("deadletter"))
+		if err != nil {
+			return err
+		}
+		popts = append(popts, processor.WithDeadLetter(m.deadLetter.Event))
+	}
+
+	if partition := m.config.Kafka.GetPartition(); partition != nil {
+		popts = append(popts, processor.WithPartition(partition))
+	}
+
+	if m.config.Severity != nil {
+		popts = append(popts, processor.WithSeverity(m.config.Severity))
 	}
 
 	if len(m.config.Filters) > 0 {
 		popts = append(popts, processor.WithFilters(m.config.Filters))
 	}
 
+	if len(m.config.Redaction) > 0 {
+		popts = append(popts, processor.WithRedaction(m.config.Redaction))
+	}
+
 	if len(m.config.Selectors) > 0 {
 		popts = append(popts, processor.WithSelectors(m.config.Selectors))
 	}
 
+	if m.config.Transform != nil {
+		popts = append(popts, processor.WithTransform(m.config.Transform))
+	}
+
+	if len(m.config.Kafka.Routes) > 0 {
+		popts = append(popts, processor.WithRoutes(m.config.Kafka.Routes))
+	}
+
+	if len(m.config.Kafka.Headers) > 0 {
+		popts = append(popts, processor.WithHeaders(m.config.Kafka.Headers))
+	}
+
+	timestamp, err := m.config.Kafka.GetTimestamp()
+	if err != nil {
+		return err
+	}
+	popts = append(popts, processor.WithTimestamp(timestamp))
+
+	policy, err := m.config.Kafka.GetOversizePolicy()
+	if err != nil {
+		return err
+	}
+	popts = append(popts, processor.WithSizeGuard(m.config.Kafka.GetMaxMessageBytes(), policy))
+
+	if m.config.Output != nil {
+		var encoder processor.Encoder
+		encoder, err = m.config.Output.GetEncoder(
+			m.config.Selectors,
+			m.config.Kafka.GetTopics(),
+		)
+		if err != nil {
+			return err
+		}
+		popts = append(popts, processor.WithEncoder(encoder))
+	}
+
 	m.processor = processor.New(
 		events,
 		popts...,
@@ -67,6 +170,12 @@ func (m *Manager) Setup() error {
 
 	eopts := []exporter.Option{
 		exporter.WithLogger(m.logger.Named("exporter")),
+		exporter.WithMaxMessageBytes(m.config.Kafka.GetMaxMessageBytes()),
+		exporter.WithRetry(m.config.Kafka.GetRetryPolicy()),
+	}
+
+	if m.config.GetGapMarkers() {
+		eopts = append(eopts, exporter.WithGapMarkers(m.config.ClusterName))
 	}
 
 	codec, err := m.config.Kafka.GetCompression()
@@ -75,6 +184,25 @@ func (m *Manager) Setup() error {
 	}
 	eopts = append(eopts, exporter.WithCompression(codec))
 
+	balancer, err := m.config.Kafka.GetBalancer()
+	if err != nil {
+		return err
+	}
+
+	if balancer != nil {
+		eopts = append(eopts, exporter.WithBalancer(balancer))
+	} else if m.config.Kafka.Key != nil {
+		// Custom keys are meant to group related events, thus the messages with the same
+		// key have to land on the same partition to preserve their order.
+		eopts = append(eopts, exporter.WithBalancer(&kafka.Hash{}))
+	}
+
+	producer, err := m.config.Kafka.GetProducerOptions()
+	if err != nil {
+		return err
+	}
+	eopts = append(eopts, producer...)
+
 	if m.config.Kafka.RawTLS != nil {
 		var config *tls.Config
 		config, err = m.config.Kafka.GetTLS()
@@ -93,6 +221,18 @@ func (m *Manager) Setup() error {
 		eopts = append(eopts, exporter.UseSASL(mechanism))
 	}
 
+	if m.deadLetter != nil {
+		eopts = append(eopts, exporter.WithDeadLetter(m.deadLetter.Message))
+	}
+
+	if m.config.Spool != nil {
+		m.spool, err = m.config.Spool.Open(m.logger.Named("spool"))
+		if err != nil {
+			return err
+		}
+		eopts = append(eopts, exporter.WithSpool(m.spool))
+	}
+
 	m.exporter = exporter.New(
 		messages,
 		m.config.Kafka.Topic,
@@ -108,11 +248,12 @@ func (m *Manager) Start(ctx context.Context) error {
 	}
 
 	m.logger.Info("starting manager...")
-	errs := make(chan error, 1)
+	// The channel holds the error of each component which may fail, so none of them blocks.
+	errs := make(chan error, 3)
 	subctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	m.wg.Add(3)
+	m.wg.Add(4)
 	go func() {
 		defer m.wg.Done()
 		if err := m.watcher.Watch(subctx); err != nil {
@@ -132,6 +273,22 @@ func (m *Manager) Start(ctx context.Context) error {
 		}
 	}()
 
+	go func() {
+		defer m.wg.Done()
+		m.logBufferStats(subctx)
+	}()
+
+	m.publishStats()
+	if m.config.Metrics != nil {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			if err := m.serveMetrics(subctx, m.config.Metrics.Address); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
 	var err error
 	select {
 	case <-ctx.Done():
@@ -142,6 +299,8 @@ func (m *Manager) Start(ctx context.Context) error {
 	}
 
 	m.wg.Wait()
+	m.close()
+
 	// Ensure that error is not lost even if the context was canceled.
 	close(errs)
 	if e, ok := <-errs; ok {
@@ -150,3 +309,142 @@ func (m *Manager) Start(ctx context.Context) error {
 
 	return err
 }
+
+// close closes the spool and the dead-letter sink, if opened.
+func (m *Manager) close() {
+	if m.spool != nil {
+		if err := m.spool.Close(); err != nil {
+			m.logger.Error("failed to close spool", zap.Error(err))
+		}
+		m.spool = nil
+	}
+
+	if m.deadLetter != nil {
+		if err := m.deadLetter.Close(); err != nil {
+			m.logger.Error("failed to close dead-letter sink", zap.Error(err))
+		}
+		m.deadLetter = nil
+	}
+}
+
+// publishStats publishes the usage of the buffers and the spool along with the counters
+// of the processor in the stats, so they are read on each request to the metrics server.
+func (m *Manager) publishStats() {
+	usage := func(buffer bufferUsage) map[string]any {
+		return map[string]any{
+			"size":  buffer.Size(),
+			"bytes": buffer.Bytes(),
+			"lost":  buffer.Lost(),
+		}
+	}
+
+	events := m.watcher.GetBuffer()
+	messages := m.processor.GetBuffer()
+	stats.Set("buffers", expvar.Func(func() any {
+		return map[string]any{
+			"events":   usage(events),
+			"messages": usage(messages),
+		}
+	}))
+
+	processed := m.processor
+	stats.Set("redactions", expvar.Func(func() any {
+		return processed.RedactionCounts()
+	}))
+	stats.Set("selectorErrors", expvar.Func(func() any {
+		return processed.SelectorErrorCounts()
+	}))
+
+	if m.spool == nil {
+		stats.Delete("spool")
+		return
+	}
+
+	spooled := m.spool
+	stats.Set("spool", expvar.Func(func() any {
+		return map[string]any{
+			"pending": spooled.Pending(),
+			"bytes":   spooled.Bytes(),
+			"lost":    spooled.Lost(),
+		}
+	}))
+}
+
+// serveMetrics serves the stats at /debug/vars until the context is done.
+func (m *Manager) serveMetrics(ctx context.Context, address string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: shutdownTimeout,
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+			return
+		}
+
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(sctx); err != nil {
+			m.logger.Error("failed to shut down metrics server", zap.Error(err))
+		}
+	}()
+
+	m.logger.Info("serving metrics", zap.String("address", address))
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("metrics server failed: %w", err)
+	}
+	return nil
+}
+
+// logBufferStats periodically logs the usage of the buffers connecting the components,
+// including the bytes accounted in their byte budgets and the data lost so far, along
+// with the counters of the processor.
+func (m *Manager) logBufferStats(ctx context.Context) {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+
+	events := m.watcher.GetBuffer()
+	messages := m.processor.GetBuffer()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.logger.Info("buffers usage",
+				zap.Dict("events",
+					zap.Int("size", events.Size()),
+					zap.Int("bytes", events.Bytes()),
+					zap.Uint64("lost", events.Lost()),
+				),
+				zap.Dict("messages",
+					zap.Int("size", messages.Size()),
+					zap.Int("bytes", messages.Bytes()),
+					zap.Uint64("lost", messages.Lost()),
+				),
+			)
+
+			if redactions := m.processor.RedactionCounts(); redactions != nil {
+				m.logger.Info("redactions made by rules", zap.Any("counts", redactions))
+			}
+
+			if errs := m.processor.SelectorErrorCounts(); len(errs) > 0 {
+				m.logger.Info("errors caused by selectors", zap.Any("counts", errs))
+			}
+
+			if m.spool != nil {
+				m.logger.Info("spool usage",
+					zap.Uint64("pending", m.spool.Pending()),
+					zap.Int64("bytes", m.spool.Bytes()),
+					zap.Uint64("lost", m.spool.Lost()),
+				)
+			}
+		}
+	}
+}
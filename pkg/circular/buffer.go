@@ -1,45 +1,272 @@
 package circular
 
 import (
+	"context"
+	"fmt"
 	"github.com/raczu/kube2kafka/pkg/assert"
 	"sync"
+	"time"
 )
 
+// DefaultBlockTimeout is the default time the writer waits for the free space
+// when the buffer uses the BlockPolicy.
+const DefaultBlockTimeout = 5 * time.Second
+
+// OverflowPolicy defines how the write to the full buffer is handled.
+type OverflowPolicy string
+
+const (
+	// OverwriteOldestPolicy overwrites the oldest data with the written value.
+	OverwriteOldestPolicy OverflowPolicy = "overwrite-oldest"
+	// DropNewestPolicy discards the written value, keeping the buffered data intact.
+	DropNewestPolicy OverflowPolicy = "drop-newest"
+	// BlockPolicy blocks the writer until the space is freed by the reader. If it does
+	// not happen within the timeout, the written value is discarded.
+	BlockPolicy OverflowPolicy = "block"
+)
+
+// MapOverflowPolicyString maps a policy string to an OverflowPolicy.
+func MapOverflowPolicyString(policy string) (OverflowPolicy, error) {
+	switch p := OverflowPolicy(policy); p {
+	case OverwriteOldestPolicy, DropNewestPolicy, BlockPolicy:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown overflow policy: %s", policy)
+	}
+}
+
+// Loss describes the data lost by the buffer due to the overflow, along with
+// the time range in which it was lost.
+type Loss struct {
+	Count int
+	From  time.Time
+	To    time.Time
+}
+
+// SizeFunc returns the size of the value in bytes.
+type SizeFunc[T any] func(value T) int
+
+type options[T any] struct {
+	policy  OverflowPolicy
+	timeout time.Duration
+	budget  int
+	sizeOf  SizeFunc[T]
+}
+
+// Option configures the buffer of the values of type T.
+type Option[T any] func(*options[T])
+
+// WithOverflowPolicy sets the policy applied when writing to the full buffer. The timeout
+// is used only by the BlockPolicy, if it is not positive, the DefaultBlockTimeout is used.
+func WithOverflowPolicy[T any](policy OverflowPolicy, timeout time.Duration) Option[T] {
+	return func(o *options[T]) {
+		o.policy = policy
+		o.timeout = timeout
+	}
+}
+
+// WithByteBudget limits the total size of the values stored in the buffer to the budget
+// in bytes, in addition to its capacity. Exceeding the budget is handled the same way as
+// exceeding the capacity, according to the overflow policy. The single value larger than
+// the budget is stored only when the buffer is empty.
+func WithByteBudget[T any](budget int, sizeOf SizeFunc[T]) Option[T] {
+	return func(o *options[T]) {
+		o.budget = budget
+		o.sizeOf = sizeOf
+	}
+}
+
+// closed is returned to the readers waiting for the data when the buffer is not empty.
+var closed = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
 // RingBuffer is a thread-safe circular structure, designed to store most recent data.
-// When the buffer is full, the oldest data is overwritten.
+// By default, when the buffer is full, the oldest data is overwritten.
 type RingBuffer[T any] struct {
 	buffer   []T
 	capacity int
 	size     int
 	head     int
 	tail     int
-	mu       sync.Mutex
+	policy   OverflowPolicy
+	timeout  time.Duration
+	// sizes holds the size of each stored value, so the bytes can be updated
+	// on read, whereas the bytes are the total size of the stored values.
+	sizes  []int
+	bytes  int
+	budget int
+	sizeOf SizeFunc[T]
+	mu     sync.Mutex
+	// notify is closed on the next write when someone waits for the data,
+	// and then replaced, so that all waiting readers are woken up at once.
+	notify  chan struct{}
+	waiting bool
+	// freed works as notify, but for the writers waiting for the free space.
+	freed    chan struct{}
+	blocking bool
+	// loss is the data lost since the last TakeLoss call, whereas lost
+	// is the total number of lost values.
+	loss Loss
+	lost uint64
 }
 
 // NewRingBuffer creates a new ring buffer with the fixed capacity.
-func NewRingBuffer[T any](capacity int) *RingBuffer[T] {
+func NewRingBuffer[T any](capacity int, opts ...Option[T]) *RingBuffer[T] {
 	assert.Assert(capacity > 0, "buffer capacity must be greater than 0")
-	return &RingBuffer[T]{
+	o := options[T]{policy: OverwriteOldestPolicy}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.timeout <= 0 {
+		o.timeout = DefaultBlockTimeout
+	}
+
+	rb := &RingBuffer[T]{
 		buffer:   make([]T, capacity),
 		capacity: capacity,
+		policy:   o.policy,
+		timeout:  o.timeout,
+		notify:   make(chan struct{}),
+		freed:    make(chan struct{}),
+	}
+
+	if o.budget > 0 && o.sizeOf != nil {
+		rb.sizes = make([]int, capacity)
+		rb.budget = o.budget
+		rb.sizeOf = o.sizeOf
 	}
+	return rb
 }
 
-// Write inserts a value to the buffer. When the buffer is full,
-// the oldest data is overwritten.
-func (rb *RingBuffer[T]) Write(value T) {
+// Write inserts a value to the buffer and returns true if the value was stored. When
+// the buffer is full, the value is handled according to the overflow policy.
+func (rb *RingBuffer[T]) Write(value T) bool {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
 
-	if rb.size == rb.capacity {
-		// Advance the tail to overwrite the oldest data.
-		rb.tail = (rb.tail + 1) % rb.capacity
-	} else {
-		rb.size++
+	var n int
+	if rb.sizeOf != nil {
+		n = rb.sizeOf(value)
+	}
+
+	if rb.full(n) {
+		switch rb.policy {
+		case DropNewestPolicy:
+			rb.recordLoss()
+			return false
+		case BlockPolicy:
+			if !rb.waitForSpace(n) {
+				rb.recordLoss()
+				return false
+			}
+		default:
+			// Advance the tail to overwrite the oldest data.
+			for rb.full(n) {
+				rb.evict()
+				rb.recordLoss()
+			}
+		}
 	}
 
 	rb.buffer[rb.head] = value
+	if rb.sizes != nil {
+		rb.sizes[rb.head] = n
+		rb.bytes += n
+	}
 	rb.head = (rb.head + 1) % rb.capacity
+	rb.size++
+
+	if rb.waiting {
+		close(rb.notify)
+		rb.notify = make(chan struct{})
+		rb.waiting = false
+	}
+	return true
+}
+
+// full checks whether the value of the given size does not fit in the buffer. It must
+// be called with the lock held.
+func (rb *RingBuffer[T]) full(n int) bool {
+	if rb.size == rb.capacity {
+		return true
+	}
+	return rb.budget > 0 && rb.size > 0 && rb.bytes+n > rb.budget
+}
+
+// evict removes the oldest value from the buffer. The slot is cleared, so the buffer does
+// not keep the removed value reachable. It must be called with the lock held.
+func (rb *RingBuffer[T]) evict() {
+	var zero T
+	rb.buffer[rb.tail] = zero
+	if rb.sizes != nil {
+		rb.bytes -= rb.sizes[rb.tail]
+		rb.sizes[rb.tail] = 0
+	}
+	rb.tail = (rb.tail + 1) % rb.capacity
+	rb.size--
+}
+
+// waitForSpace releases the lock until the reader frees the space for the value of
+// the given size or the timeout elapses. It must be called with the lock held and
+// returns true if there is the free space in the buffer.
+func (rb *RingBuffer[T]) waitForSpace(n int) bool {
+	timer := time.NewTimer(rb.timeout)
+	defer timer.Stop()
+
+	for rb.full(n) {
+		rb.blocking = true
+		freed := rb.freed
+		rb.mu.Unlock()
+
+		select {
+		case <-freed:
+			rb.mu.Lock()
+		case <-timer.C:
+			rb.mu.Lock()
+			return !rb.full(n)
+		}
+	}
+	return true
+}
+
+// recordLoss accounts the single lost value. It must be called with the lock held.
+func (rb *RingBuffer[T]) recordLoss() {
+	now := time.Now()
+	if rb.loss.Count == 0 {
+		rb.loss.From = now
+	}
+	rb.loss.Count++
+	rb.loss.To = now
+	rb.lost++
+}
+
+// release wakes up the writers waiting for the free space. It must be called
+// with the lock held.
+func (rb *RingBuffer[T]) release() {
+	if rb.blocking {
+		close(rb.freed)
+		rb.freed = make(chan struct{})
+		rb.blocking = false
+	}
+}
+
+// NotEmpty returns a channel that is closed as soon as the buffer holds any data. If the
+// buffer is not empty, the returned channel is already closed. It allows the readers to
+// wait for the data instead of polling the buffer.
+func (rb *RingBuffer[T]) NotEmpty() <-chan struct{} {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.size > 0 {
+		return closed
+	}
+
+	rb.waiting = true
+	return rb.notify
 }
 
 // Read returns the next value from buffer and true if the value
@@ -54,15 +281,147 @@ func (rb *RingBuffer[T]) Read() (T, bool) {
 	}
 
 	value := rb.buffer[rb.tail]
-	rb.tail = (rb.tail + 1) % rb.capacity
-	rb.size--
+	rb.evict()
+	rb.release()
 
 	return value, true
 }
 
+// ReadN returns up to n next values from the buffer at once. When the buffer
+// is empty, it returns nil.
+func (rb *RingBuffer[T]) ReadN(n int) []T {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.readN(n)
+}
+
+// Drain returns all values from the buffer, leaving it empty.
+func (rb *RingBuffer[T]) Drain() []T {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.readN(rb.size)
+}
+
+// readN reads up to n values from the buffer. It must be called with the lock held.
+func (rb *RingBuffer[T]) readN(n int) []T {
+	n = min(n, rb.size)
+	if n <= 0 {
+		return nil
+	}
+
+	values := make([]T, n)
+	for i := range values {
+		values[i] = rb.buffer[rb.tail]
+		rb.evict()
+	}
+	rb.release()
+
+	return values
+}
+
+// ReadWait returns the next value from the buffer, waiting for it if the buffer is empty.
+// It returns the context error if the context is done before any value is read.
+func (rb *RingBuffer[T]) ReadWait(ctx context.Context) (T, error) {
+	for {
+		if value, ok := rb.Read(); ok {
+			return value, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		case <-rb.NotEmpty():
+		}
+	}
+}
+
+// Peek returns the next value from the buffer without removing it and true if
+// the value exists. When the buffer is empty, it returns false.
+func (rb *RingBuffer[T]) Peek() (T, bool) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.size == 0 {
+		var zero T
+		return zero, false
+	}
+	return rb.buffer[rb.tail], true
+}
+
+// Resize changes the capacity of the buffer, preserving the order of the stored values.
+// When the new capacity is lower than the size, the oldest values are dropped and
+// accounted as lost.
+func (rb *RingBuffer[T]) Resize(capacity int) {
+	assert.Assert(capacity > 0, "buffer capacity must be greater than 0")
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	for rb.size > capacity {
+		rb.evict()
+		rb.recordLoss()
+	}
+
+	buffer := make([]T, capacity)
+	var sizes []int
+	if rb.sizes != nil {
+		sizes = make([]int, capacity)
+	}
+
+	for i := 0; i < rb.size; i++ {
+		j := (rb.tail + i) % rb.capacity
+		buffer[i] = rb.buffer[j]
+		if sizes != nil {
+			sizes[i] = rb.sizes[j]
+		}
+	}
+
+	rb.buffer = buffer
+	rb.sizes = sizes
+	rb.capacity = capacity
+	rb.tail = 0
+	rb.head = rb.size % capacity
+	// Wake up the writers waiting for the free space, as the buffer may have grown.
+	rb.release()
+}
+
+// Capacity returns the maximum number of elements the buffer can hold.
+func (rb *RingBuffer[T]) Capacity() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.capacity
+}
+
+// Bytes returns the total size of the values stored in the buffer. It is always zero
+// if the buffer has no byte budget.
+func (rb *RingBuffer[T]) Bytes() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.bytes
+}
+
 // Size returns the number of elements in the buffer.
 func (rb *RingBuffer[T]) Size() int {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
 	return rb.size
 }
+
+// Lost returns the total number of values lost due to the overflow, either
+// overwritten or discarded, since the buffer was created.
+func (rb *RingBuffer[T]) Lost() uint64 {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.lost
+}
+
+// TakeLoss returns the data lost since the previous call and resets it, so each
+// loss is reported only once.
+func (rb *RingBuffer[T]) TakeLoss() Loss {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	loss := rb.loss
+	rb.loss = Loss{}
+	return loss
+}
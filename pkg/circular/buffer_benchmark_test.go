@@ -0,0 +1,81 @@
+package circular_test
+
+import (
+	"github.com/raczu/kube2kafka/pkg/circular"
+	"runtime"
+	"testing"
+)
+
+// BenchmarkRingBufferNotEmpty measures the throughput of the producer and the consumer
+// waiting for the data, which mirrors how the pipeline stages are connected.
+func BenchmarkRingBufferNotEmpty(b *testing.B) {
+	buffer := circular.NewRingBuffer[int](128)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for read := 0; read < b.N; {
+			<-buffer.NotEmpty()
+			for {
+				if _, ok := buffer.Read(); !ok {
+					break
+				}
+				read++
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Wait for the consumer to keep up, so no value is overwritten.
+		for buffer.Size() == 128 {
+			runtime.Gosched()
+		}
+		buffer.Write(i)
+	}
+	<-done
+}
+
+// BenchmarkRingBufferBatch compares building the batch by reading the values one by one,
+// as the exporter used to do, with reading them at once.
+func BenchmarkRingBufferBatch(b *testing.B) {
+	const capacity, batch = 1024, 100
+
+	readLoop := func(buffer *circular.RingBuffer[*int], n int) []*int {
+		var values []*int
+		for i := 0; i < n; i++ {
+			value, ok := buffer.Read()
+			if !ok {
+				break
+			}
+			values = append(values, value)
+		}
+		return values
+	}
+
+	readN := func(buffer *circular.RingBuffer[*int], n int) []*int {
+		return buffer.ReadN(n)
+	}
+
+	for name, read := range map[string]func(*circular.RingBuffer[*int], int) []*int{
+		"Read":  readLoop,
+		"ReadN": readN,
+	} {
+		b.Run(name, func(b *testing.B) {
+			buffer := circular.NewRingBuffer[*int](capacity)
+			value := new(int)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < batch; j++ {
+					buffer.Write(value)
+				}
+
+				if values := read(buffer, batch); len(values) != batch {
+					b.Fatalf("read %d values, expected %d", len(values), batch)
+				}
+			}
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package circular_test
 
 import (
+	"context"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/raczu/kube2kafka/pkg/circular"
+	"runtime"
+	"sync"
+	"time"
 )
 
 var _ = Describe("Buffer", func() {
@@ -105,5 +109,396 @@ var _ = Describe("Buffer", func() {
 				Expect(ok).To(BeFalse())
 			})
 		})
+
+		It("should not keep the read values reachable", func() {
+			type payload struct{ data [4096]byte }
+			released := make(chan struct{})
+
+			pointers := circular.NewRingBuffer[*payload](4)
+			value := &payload{}
+			runtime.SetFinalizer(value, func(*payload) { close(released) })
+			pointers.Write(value)
+			value = nil
+
+			_, ok := pointers.Read()
+			Expect(ok).To(BeTrue())
+
+			Eventually(func() bool {
+				runtime.GC()
+				select {
+				case <-released:
+					return true
+				default:
+					return false
+				}
+			}, time.Second, 10*time.Millisecond).Should(BeTrue())
+			runtime.KeepAlive(pointers)
+		})
+	})
+
+	When("waiting for the data", func() {
+		BeforeEach(func() {
+			capacity = 10
+			buffer = circular.NewRingBuffer[int](capacity)
+		})
+
+		It("should return closed channel if the buffer is not empty", func() {
+			buffer.Write(1)
+			Expect(buffer.NotEmpty()).To(BeClosed())
+		})
+
+		It("should close the channel on the next write", func() {
+			ch := buffer.NotEmpty()
+			Expect(ch).NotTo(BeClosed())
+
+			buffer.Write(1)
+			Expect(ch).To(BeClosed())
+		})
+
+		It("should wake up all waiting readers", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				ch := buffer.NotEmpty()
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-ch
+				}()
+			}
+
+			buffer.Write(1)
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("should return new channel once the buffer is drained", func() {
+			buffer.Write(1)
+			buffer.Read()
+			Expect(buffer.NotEmpty()).NotTo(BeClosed())
+		})
+	})
+
+	When("applying the overflow policy", func() {
+		fill := func() {
+			for i := 0; i < capacity; i++ {
+				Expect(buffer.Write(i)).To(BeTrue())
+			}
+		}
+
+		BeforeEach(func() {
+			capacity = 10
+		})
+
+		It("should return an error if the policy is unknown", func() {
+			_, err := circular.MapOverflowPolicyString("drop-oldest")
+			Expect(err).To(HaveOccurred())
+		})
+
+		Context("and the policy is drop-newest", func() {
+			BeforeEach(func() {
+				buffer = circular.NewRingBuffer[int](
+					capacity,
+					circular.WithOverflowPolicy[int](circular.DropNewestPolicy, 0),
+				)
+				fill()
+			})
+
+			It("should discard the written value and keep the oldest data", func() {
+				Expect(buffer.Write(capacity)).To(BeFalse())
+				Expect(buffer.Size()).To(Equal(capacity))
+
+				value, ok := buffer.Read()
+				Expect(ok).To(BeTrue())
+				Expect(value).To(Equal(0))
+			})
+		})
+
+		Context("and the policy is block", func() {
+			BeforeEach(func() {
+				buffer = circular.NewRingBuffer[int](
+					capacity,
+					circular.WithOverflowPolicy[int](circular.BlockPolicy, 50*time.Millisecond),
+				)
+				fill()
+			})
+
+			It("should discard the written value after the timeout", func() {
+				start := time.Now()
+				Expect(buffer.Write(capacity)).To(BeFalse())
+				Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+				Expect(buffer.Size()).To(Equal(capacity))
+			})
+
+			It("should store the written value once the space is freed", func() {
+				written := make(chan bool)
+				go func() {
+					written <- buffer.Write(capacity)
+				}()
+
+				Consistently(written, 20*time.Millisecond).ShouldNot(Receive())
+				buffer.Read()
+				Eventually(written).Should(Receive(BeTrue()))
+
+				for i := 1; i <= capacity; i++ {
+					value, ok := buffer.Read()
+					Expect(ok).To(BeTrue())
+					Expect(value).To(Equal(i))
+				}
+			})
+
+			It("should not lose any value written concurrently", func() {
+				const writers, values = 4, 100
+				buffer = circular.NewRingBuffer[int](
+					capacity,
+					circular.WithOverflowPolicy[int](circular.BlockPolicy, time.Minute),
+				)
+
+				var wg sync.WaitGroup
+				for w := 0; w < writers; w++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for i := 0; i < values; i++ {
+							buffer.Write(i)
+						}
+					}()
+				}
+
+				read := 0
+				for read < writers*values {
+					if _, ok := buffer.Read(); ok {
+						read++
+						continue
+					}
+					<-buffer.NotEmpty()
+				}
+				wg.Wait()
+				Expect(buffer.Size()).To(BeZero())
+			})
+		})
+	})
+
+	When("accounting the lost data", func() {
+		BeforeEach(func() {
+			capacity = 2
+			buffer = circular.NewRingBuffer[int](capacity)
+		})
+
+		It("should report no loss if the buffer did not overflow", func() {
+			buffer.Write(1)
+			Expect(buffer.Lost()).To(BeZero())
+			Expect(buffer.TakeLoss()).To(Equal(circular.Loss{}))
+		})
+
+		It("should count the overwritten values", func() {
+			before := time.Now()
+			for i := 0; i < capacity+3; i++ {
+				buffer.Write(i)
+			}
+
+			loss := buffer.TakeLoss()
+			Expect(loss.Count).To(Equal(3))
+			Expect(loss.From).To(BeTemporally(">=", before))
+			Expect(loss.To).To(BeTemporally(">=", loss.From))
+		})
+
+		It("should count the discarded values", func() {
+			buffer = circular.NewRingBuffer[int](
+				capacity,
+				circular.WithOverflowPolicy[int](circular.DropNewestPolicy, 0),
+			)
+			for i := 0; i < capacity+2; i++ {
+				buffer.Write(i)
+			}
+			Expect(buffer.TakeLoss().Count).To(Equal(2))
+		})
+
+		It("should reset the loss once taken, but keep the total", func() {
+			for i := 0; i < capacity+1; i++ {
+				buffer.Write(i)
+			}
+			Expect(buffer.TakeLoss().Count).To(Equal(1))
+			Expect(buffer.TakeLoss().Count).To(BeZero())
+			Expect(buffer.Lost()).To(Equal(uint64(1)))
+		})
+	})
+
+	When("using the batch and waiting reads", func() {
+		BeforeEach(func() {
+			capacity = 5
+			buffer = circular.NewRingBuffer[int](capacity)
+			for i := 0; i < 3; i++ {
+				buffer.Write(i)
+			}
+		})
+
+		It("should read up to n values in the correct order", func() {
+			Expect(buffer.ReadN(2)).To(Equal([]int{0, 1}))
+			Expect(buffer.ReadN(5)).To(Equal([]int{2}))
+			Expect(buffer.ReadN(5)).To(BeNil())
+		})
+
+		It("should read the wrapped values in the correct order", func() {
+			for i := 3; i < 7; i++ {
+				buffer.Write(i)
+			}
+			Expect(buffer.Drain()).To(Equal([]int{2, 3, 4, 5, 6}))
+			Expect(buffer.Size()).To(BeZero())
+		})
+
+		It("should peek the next value without removing it", func() {
+			value, ok := buffer.Peek()
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal(0))
+			Expect(buffer.Size()).To(Equal(3))
+
+			buffer.Drain()
+			_, ok = buffer.Peek()
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should wait for the value to be written", func() {
+			buffer.Drain()
+			read := make(chan int)
+			go func() {
+				defer GinkgoRecover()
+				value, err := buffer.ReadWait(context.Background())
+				Expect(err).NotTo(HaveOccurred())
+				read <- value
+			}()
+
+			Consistently(read, 20*time.Millisecond).ShouldNot(Receive())
+			buffer.Write(7)
+			Eventually(read).Should(Receive(Equal(7)))
+		})
+
+		It("should stop waiting once the context is done", func() {
+			buffer.Drain()
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			_, err := buffer.ReadWait(ctx)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+		})
+
+		It("should keep the values when growing", func() {
+			buffer.Resize(10)
+			Expect(buffer.Capacity()).To(Equal(10))
+			for i := 3; i < 10; i++ {
+				Expect(buffer.Write(i)).To(BeTrue())
+			}
+			Expect(buffer.TakeLoss().Count).To(BeZero())
+			Expect(buffer.Drain()).To(HaveLen(10))
+		})
+
+		It("should drop the oldest values when shrinking", func() {
+			buffer.Resize(2)
+			Expect(buffer.TakeLoss().Count).To(Equal(1))
+			Expect(buffer.Drain()).To(Equal([]int{1, 2}))
+		})
+
+		It("should wake up the blocked writer when growing", func() {
+			buffer = circular.NewRingBuffer[int](
+				1,
+				circular.WithOverflowPolicy[int](circular.BlockPolicy, time.Minute),
+			)
+			buffer.Write(0)
+
+			written := make(chan bool)
+			go func() {
+				written <- buffer.Write(1)
+			}()
+
+			Consistently(written, 20*time.Millisecond).ShouldNot(Receive())
+			buffer.Resize(2)
+			Eventually(written).Should(Receive(BeTrue()))
+			Expect(buffer.Drain()).To(Equal([]int{0, 1}))
+		})
+	})
+
+	When("limiting the buffer by the byte budget", func() {
+		var texts *circular.RingBuffer[string]
+
+		size := func(value string) int {
+			return len(value)
+		}
+
+		BeforeEach(func() {
+			texts = circular.NewRingBuffer[string](
+				10,
+				circular.WithByteBudget(10, size),
+			)
+		})
+
+		It("should track the bytes of the stored values", func() {
+			texts.Write("abc")
+			texts.Write("de")
+			Expect(texts.Bytes()).To(Equal(5))
+
+			texts.Read()
+			Expect(texts.Bytes()).To(Equal(2))
+			texts.Drain()
+			Expect(texts.Bytes()).To(BeZero())
+		})
+
+		It("should overwrite the oldest values until the value fits", func() {
+			texts.Write("abcd")
+			texts.Write("efgh")
+			Expect(texts.Write("ijklmn")).To(BeTrue())
+
+			Expect(texts.TakeLoss().Count).To(Equal(1))
+			Expect(texts.Drain()).To(Equal([]string{"efgh", "ijklmn"}))
+		})
+
+		It("should store the value exceeding the budget if the buffer is empty", func() {
+			Expect(texts.Write("abcdefghijkl")).To(BeTrue())
+			Expect(texts.Bytes()).To(Equal(12))
+
+			Expect(texts.Write("a")).To(BeTrue())
+			Expect(texts.Drain()).To(Equal([]string{"a"}))
+		})
+
+		It("should drop the newest value if the policy is drop-newest", func() {
+			texts = circular.NewRingBuffer[string](
+				10,
+				circular.WithByteBudget(10, size),
+				circular.WithOverflowPolicy[string](circular.DropNewestPolicy, 0),
+			)
+			texts.Write("abcdefgh")
+			Expect(texts.Write("ijk")).To(BeFalse())
+			Expect(texts.Write("ij")).To(BeTrue())
+			Expect(texts.Bytes()).To(Equal(10))
+		})
+
+		It("should block the writer until the bytes are freed", func() {
+			texts = circular.NewRingBuffer[string](
+				10,
+				circular.WithByteBudget(10, size),
+				circular.WithOverflowPolicy[string](circular.BlockPolicy, time.Minute),
+			)
+			texts.Write("abcdefgh")
+
+			written := make(chan bool)
+			go func() {
+				written <- texts.Write("ijk")
+			}()
+
+			Consistently(written, 20*time.Millisecond).ShouldNot(Receive())
+			texts.Read()
+			Eventually(written).Should(Receive(BeTrue()))
+			Expect(texts.Bytes()).To(Equal(3))
+		})
+
+		It("should keep the bytes when resizing", func() {
+			texts.Write("abc")
+			texts.Write("de")
+			texts.Resize(1)
+			Expect(texts.Bytes()).To(Equal(2))
+			Expect(texts.Drain()).To(Equal([]string{"de"}))
+		})
 	})
 })
@@ -0,0 +1,107 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	log "github.com/raczu/kube2kafka/pkg/logger"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// Sink is the destination of the records. It must be safe for concurrent use, as
+// the messages are dropped by both the processor and the exporter.
+type Sink interface {
+	Write(record *Record) error
+	Close() error
+}
+
+type Option func(*DeadLetter)
+
+// DeadLetter writes the messages which cannot be delivered to Kafka to the sink, so
+// they do not vanish and can be replayed later.
+type DeadLetter struct {
+	sink Sink
+	// topic is the default topic set in the records of the messages without the topic.
+	topic  string
+	logger *zap.Logger
+}
+
+func New(sink Sink, opts ...Option) *DeadLetter {
+	d := &DeadLetter{
+		sink:   sink,
+		logger: log.New().Named("deadletter"),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Event writes the message dropped by the processor. It conforms to the
+// processor.DeadLetterFunc, the event is recorded only if the message has no value.
+func (d *DeadLetter) Event(event *kube.EnhancedEvent, message *kafka.Message, err error) {
+	record := NewRecord(ProcessorSource, message, 0, err)
+	if message.Value == nil {
+		data, merr := json.Marshal(event)
+		if merr != nil {
+			d.logger.Error("failed to marshal the event of dead-letter record",
+				zap.String("namespace", event.Namespace),
+				zap.String("name", event.Name),
+				zap.Error(merr),
+			)
+		}
+		record.Event = data
+	}
+	d.write(record)
+}
+
+// Message writes the message dropped by the exporter. It conforms to the
+// exporter.DeadLetterFunc.
+func (d *DeadLetter) Message(message *kafka.Message, attempts int, err error) {
+	d.write(NewRecord(ExporterSource, message, attempts, err))
+}
+
+func (d *DeadLetter) write(record *Record) {
+	if record.Topic == "" {
+		record.Topic = d.topic
+	}
+
+	if err := d.sink.Write(record); err != nil {
+		d.logger.Error("failed to write dead-letter record, dropping message",
+			zap.String("topic", record.Topic),
+			zap.ByteString("key", record.Key),
+			zap.String("reason", record.Error),
+			zap.Error(err),
+		)
+		return
+	}
+
+	d.logger.Warn("message written to dead-letter sink",
+		zap.String("source", record.Source),
+		zap.String("topic", record.Topic),
+		zap.ByteString("key", record.Key),
+		zap.Int("attempts", record.Attempts),
+		zap.String("reason", record.Error),
+	)
+}
+
+// Close closes the sink, flushing the pending records.
+func (d *DeadLetter) Close() error {
+	return d.sink.Close()
+}
+
+// WithDefaultTopic sets the topic recorded for the messages without the topic, which
+// are meant to be sent to the default topic.
+func WithDefaultTopic(topic string) Option {
+	return func(d *DeadLetter) {
+		d.topic = topic
+	}
+}
+
+// WithLogger sets the logger for the dead letter.
+func WithLogger(logger *zap.Logger) Option {
+	return func(d *DeadLetter) {
+		d.logger = logger
+	}
+}
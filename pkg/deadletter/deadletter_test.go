@@ -0,0 +1,186 @@
+package deadletter_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/internal/kafkatest"
+	"github.com/raczu/kube2kafka/pkg/deadletter"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	log "github.com/raczu/kube2kafka/pkg/logger"
+	"github.com/segmentio/kafka-go"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var _ = Describe("Dead letter", func() {
+	var path string
+
+	records := func(path string) []deadletter.Record {
+		file, err := os.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+
+		var records []deadletter.Record
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var record deadletter.Record
+			Expect(json.Unmarshal(scanner.Bytes(), &record)).To(Succeed())
+			records = append(records, record)
+		}
+		Expect(scanner.Err()).NotTo(HaveOccurred())
+		return records
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "dead-letter.jsonl")
+	})
+
+	When("writing the dropped messages", func() {
+		var dl *deadletter.DeadLetter
+
+		BeforeEach(func() {
+			sink, err := deadletter.NewFileSink(path, deadletter.DefaultMaxFileBytes, 1)
+			Expect(err).NotTo(HaveOccurred())
+			dl = deadletter.New(
+				sink,
+				deadletter.WithDefaultTopic("k8s-events"),
+				deadletter.WithLogger(log.New()),
+			)
+		})
+
+		AfterEach(func() {
+			Expect(dl.Close()).To(Succeed())
+		})
+
+		It("should record the message so it can be replayed", func() {
+			message := &kafka.Message{
+				Topic:   "k8s-warnings",
+				Key:     []byte("key"),
+				Value:   []byte(`{"reason":"BackOff"}`),
+				Headers: []kafka.Header{{Key: "cluster", Value: []byte("dev")}},
+				Time:    time.Now().UTC().Truncate(time.Millisecond),
+			}
+			dl.Message(message, 3, errors.New("leader not available"))
+
+			recorded := records(path)
+			Expect(recorded).To(HaveLen(1))
+			Expect(recorded[0].Source).To(Equal(deadletter.ExporterSource))
+			Expect(recorded[0].Error).To(Equal("leader not available"))
+			Expect(recorded[0].Attempts).To(Equal(3))
+			Expect(recorded[0].Timestamp).NotTo(BeZero())
+			Expect(recorded[0].Event).To(BeEmpty())
+
+			replayed := recorded[0].Message()
+			Expect(replayed.Topic).To(Equal(message.Topic))
+			Expect(replayed.Key).To(Equal(message.Key))
+			Expect(replayed.Value).To(Equal(message.Value))
+			Expect(replayed.Headers).To(Equal(message.Headers))
+			Expect(replayed.Time.Equal(message.Time)).To(BeTrue())
+		})
+
+		It("should record the event which failed to be encoded", func() {
+			event := &kube.EnhancedEvent{ClusterName: "dev.kube2kafka.local"}
+			event.Reason = "BackOff"
+			dl.Event(event, &kafka.Message{Key: []byte("key")}, errors.New("encoding failed"))
+
+			recorded := records(path)
+			Expect(recorded).To(HaveLen(1))
+			Expect(recorded[0].Source).To(Equal(deadletter.ProcessorSource))
+			Expect(recorded[0].Topic).To(Equal("k8s-events"))
+			Expect(recorded[0].Attempts).To(BeZero())
+			Expect(recorded[0].Value).To(BeNil())
+
+			var recordedEvent kube.EnhancedEvent
+			Expect(json.Unmarshal(recorded[0].Event, &recordedEvent)).To(Succeed())
+			Expect(recordedEvent.Reason).To(Equal("BackOff"))
+		})
+	})
+
+	When("the file reaches the maximum size", func() {
+		It("should rotate the file keeping the given number of backups", func() {
+			sink, err := deadletter.NewFileSink(path, 256, 2)
+			Expect(err).NotTo(HaveOccurred())
+			defer sink.Close()
+
+			for i := 0; i < 8; i++ {
+				record := deadletter.NewRecord(
+					deadletter.ExporterSource,
+					&kafka.Message{Key: []byte{byte('a' + i)}, Value: []byte("value")},
+					1,
+					errors.New("failed"),
+				)
+				Expect(sink.Write(record)).To(Succeed())
+			}
+
+			matches, err := filepath.Glob(path + "*")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matches).To(ConsistOf(path, path+".1", path+".2"))
+
+			for _, match := range matches {
+				info, err := os.Stat(match)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Size()).To(BeNumerically("<=", 256))
+			}
+
+			// The newest record is in the current file.
+			recorded := records(path)
+			Expect(recorded[len(recorded)-1].Key).To(Equal([]byte("h")))
+		})
+	})
+
+	When("the record exceeds the maximum size of the kafka sink", func() {
+		It("should truncate the value and mark the record as truncated", func() {
+			transport := &kafkatest.Transport{Keep: true}
+			writer := &kafka.Writer{
+				Addr:       kafka.TCP("localhost:9092"),
+				Topic:      "k8s-events-dead-letter",
+				BatchBytes: 4096,
+				Transport:  transport,
+			}
+			dl := deadletter.New(
+				deadletter.NewKafkaSink(writer, time.Second),
+				deadletter.WithLogger(log.New()),
+			)
+
+			message := &kafka.Message{Key: []byte("key"), Value: make([]byte, 8192)}
+			dl.Message(message, 0, errors.New("message size exceeds the limit"))
+			Expect(dl.Close()).To(Succeed())
+
+			messages := transport.Messages()
+			Expect(messages).To(HaveLen(1))
+			Expect(len(messages[0].Value)).To(BeNumerically("<=", 4096))
+
+			var record deadletter.Record
+			Expect(json.Unmarshal(messages[0].Value, &record)).To(Succeed())
+			Expect(record.Truncated).To(BeTrue())
+			Expect(record.Value).NotTo(BeEmpty())
+			Expect(len(record.Value)).To(BeNumerically("<", 8192))
+			Expect(record.Key).To(Equal([]byte("key")))
+		})
+	})
+
+	When("the broker rejects the record sent to the kafka sink", func() {
+		It("should report the failure once the record is sent", func() {
+			transport := &kafkatest.Transport{}
+			transport.Reject("k8s-events-dead-letter", kafka.NotEnoughReplicas)
+			writer := &kafka.Writer{
+				Addr:        kafka.TCP("localhost:9092"),
+				Topic:       "k8s-events-dead-letter",
+				MaxAttempts: 1,
+				Transport:   transport,
+			}
+			sink := deadletter.NewKafkaSink(writer, time.Second)
+			defer sink.Close()
+
+			message := &kafka.Message{Key: []byte("key"), Value: []byte("value")}
+			err := errors.New("message exhausted the retry budget")
+			record := deadletter.NewRecord(deadletter.ExporterSource, message, 1, err)
+			Expect(sink.Write(record)).To(MatchError(kafka.NotEnoughReplicas))
+			Expect(transport.Produced()).To(BeZero())
+		})
+	})
+})
@@ -0,0 +1,144 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	DefaultMaxFileBytes = 64 << 20
+	DefaultMaxBackups   = 3
+)
+
+// FileSink writes the records as JSON lines to the file, which is rotated once it
+// reaches the maximum size. The rotated files are suffixed with the sequence number,
+// the lower number the newer file, and only the given number of them is kept.
+type FileSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	mu         sync.Mutex
+}
+
+// NewFileSink opens the file sink, appending the records to the existing file. The maximum
+// size must be positive, whereas zero backups means the file is truncated on rotation.
+func NewFileSink(path string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+
+	s := &FileSink{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+	}
+
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat dead-letter file: %w", err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// backup returns the path of the rotated file with the given sequence number.
+func (s *FileSink) backup(n int) string {
+	return fmt.Sprintf("%s.%d", s.path, n)
+}
+
+// rotate moves the current file to the first backup, shifting the older ones and
+// removing the oldest, and opens the new file.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close dead-letter file: %w", err)
+	}
+
+	if s.maxBackups == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove dead-letter file: %w", err)
+		}
+		return s.open()
+	}
+
+	if err := os.Remove(s.backup(s.maxBackups)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove dead-letter file: %w", err)
+	}
+
+	for n := s.maxBackups - 1; n > 0; n-- {
+		if err := os.Rename(s.backup(n), s.backup(n+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate dead-letter file: %w", err)
+		}
+	}
+
+	if err := os.Rename(s.path, s.backup(1)); err != nil {
+		return fmt.Errorf("failed to rotate dead-letter file: %w", err)
+	}
+	return s.open()
+}
+
+// Write appends the record to the file and syncs it, as the records are rare, but
+// expected to survive the crash.
+func (s *FileSink) Write(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead-letter record: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("dead-letter file is closed")
+	}
+
+	if s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+		if err = s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write dead-letter record: %w", err)
+	}
+
+	if err = s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync dead-letter file: %w", err)
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,83 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+const (
+	// Type is the value of the processor.TypeHeader set on the records sent to Kafka.
+	Type = "dead-letter"
+	// DefaultSendTimeout is the default time the record is being sent for.
+	DefaultSendTimeout = 10 * time.Second
+)
+
+// KafkaSink sends the records as JSON to the dead-letter topic, keyed with the key of
+// the original message. Records larger than the batch bytes of the writer are truncated,
+// as the writer would reject them.
+type KafkaSink struct {
+	writer   *kafka.Writer
+	maxBytes int
+	timeout  time.Duration
+}
+
+// NewKafkaSink creates the sink sending the records with the writer, which must have the
+// topic set. The records are mostly dropped when Kafka is unhealthy, thus each of them is
+// sent synchronously and acknowledged by all in-sync replicas, so the failure is reported
+// rather than unnoticed. The timeout bounds the time the pipeline is blocked by the record,
+// if it is not positive, the DefaultSendTimeout is used.
+func NewKafkaSink(writer *kafka.Writer, timeout time.Duration) *KafkaSink {
+	// The writer limits the batch to 1 MiB by default, the same as the message.
+	maxBytes := processor.DefaultMaxMessageBytes
+	if writer.BatchBytes > 0 {
+		maxBytes = int(writer.BatchBytes)
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultSendTimeout
+	}
+
+	writer.Async = false
+	writer.RequiredAcks = kafka.RequireAll
+	// The record is sent right away, rather than waiting for the batch to fill.
+	writer.BatchSize = 1
+	return &KafkaSink{writer: writer, maxBytes: maxBytes, timeout: timeout}
+}
+
+func (s *KafkaSink) Write(record *Record) error {
+	for {
+		value, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal dead-letter record: %w", err)
+		}
+
+		message := kafka.Message{
+			Key:     record.Key,
+			Value:   value,
+			Headers: []kafka.Header{{Key: processor.TypeHeader, Value: []byte(Type)}},
+		}
+
+		excess := processor.MessageSize(&message) - s.maxBytes
+		if excess <= 0 || !record.truncate(excess) {
+			ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+			defer cancel()
+
+			// The record is written alone, thus its own error is reported.
+			err = s.writer.WriteMessages(ctx, message)
+			var werrs kafka.WriteErrors
+			if errors.As(err, &werrs) && len(werrs) == 1 {
+				return werrs[0]
+			}
+			return err
+		}
+	}
+}
+
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,110 @@
+package deadletter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+const (
+	// ProcessorSource marks the records of the messages dropped by the processor.
+	ProcessorSource = "processor"
+	// ExporterSource marks the records of the messages dropped by the exporter.
+	ExporterSource = "exporter"
+	// RecordOverhead bounds the size of the record besides its encoded message, i.e. the
+	// remaining fields, the error and the key the record is sent with.
+	RecordOverhead = 16 * 1024
+)
+
+// MaxRecordBytes returns the size of the record carrying the message of n bytes, whose
+// binary fields grow by a third once encoded as base64.
+func MaxRecordBytes(n int) int {
+	return base64.StdEncoding.EncodedLen(n) + RecordOverhead
+}
+
+type Header struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Record is the undeliverable message along with the reason it was dropped. It holds
+// the original message as it was meant to be sent, so it can be replayed later. The
+// binary fields are encoded as base64 strings in JSON.
+type Record struct {
+	// Source is the component which dropped the message, either processor or exporter.
+	Source  string    `json:"source"`
+	Topic   string    `json:"topic"`
+	Key     []byte    `json:"key"`
+	Value   []byte    `json:"value"`
+	Headers []Header  `json:"headers,omitempty"`
+	Time    time.Time `json:"time,omitempty"`
+	// Event is the original event, set only when it failed to be encoded, so there
+	// is no value to be replayed.
+	Event json.RawMessage `json:"event,omitempty"`
+	Error string          `json:"error"`
+	// Attempts is the number of attempts to write the message to Kafka, which is zero
+	// if the message was dropped before being written.
+	Attempts  int       `json:"attempts"`
+	Timestamp time.Time `json:"timestamp"`
+	// Truncated marks the record whose value was cut, or event dropped, to fit the
+	// maximum size of the sink, thus it cannot be replayed as it is.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// NewRecord creates the record of the message dropped due to the error.
+func NewRecord(source string, message *kafka.Message, attempts int, err error) *Record {
+	record := &Record{
+		Source:    source,
+		Topic:     message.Topic,
+		Key:       message.Key,
+		Value:     message.Value,
+		Time:      message.Time.UTC(),
+		Attempts:  attempts,
+		Timestamp: time.Now().UTC(),
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	for _, header := range message.Headers {
+		record.Headers = append(record.Headers, Header{Key: header.Key, Value: header.Value})
+	}
+	return record
+}
+
+// truncate cuts the value of the record, or drops the event if there is no value, so
+// its JSON encoding shrinks by at least n bytes. It returns false if there is nothing
+// left to cut.
+func (r *Record) truncate(n int) bool {
+	switch {
+	case len(r.Value) > 0:
+		// Every 3 bytes of the value take 4 bytes once encoded as base64.
+		r.Value = r.Value[:max(0, len(r.Value)-(n*3/4+3))]
+	case len(r.Event) > 0:
+		r.Event = nil
+	default:
+		return false
+	}
+	r.Truncated = true
+	return true
+}
+
+// Message returns the original message of the record, so it can be sent again.
+func (r *Record) Message() kafka.Message {
+	message := kafka.Message{
+		Topic: r.Topic,
+		Key:   r.Key,
+		Value: r.Value,
+		Time:  r.Time,
+	}
+
+	for _, header := range r.Headers {
+		message.Headers = append(message.Headers, kafka.Header{
+			Key:   header.Key,
+			Value: header.Value,
+		})
+	}
+	return message
+}
@@ -87,3 +87,49 @@ func MapMechanismString(mechanism string) (MechanismFactory, error) {
 	}
 	return nil, fmt.Errorf("unknown mechanism: %s", mechanism)
 }
+
+var acks2required = map[string]kafka.RequiredAcks{
+	"none": kafka.RequireNone,
+	"one":  kafka.RequireOne,
+	"all":  kafka.RequireAll,
+}
+
+// MapRequiredAcksString maps a required acks string to a kafka.RequiredAcks.
+func MapRequiredAcksString(acks string) (kafka.RequiredAcks, error) {
+	if a, ok := acks2required[acks]; ok {
+		return a, nil
+	}
+	return 0, fmt.Errorf("unknown required acks: %s", acks)
+}
+
+var partitioner2factory = map[string]func() kafka.Balancer{
+	"least-bytes": func() kafka.Balancer { return &kafka.LeastBytes{} },
+	"round-robin": func() kafka.Balancer { return &kafka.RoundRobin{} },
+	"hash":        func() kafka.Balancer { return &kafka.Hash{} },
+	"murmur2":     func() kafka.Balancer { return kafka.Murmur2Balancer{} },
+	"crc32":       func() kafka.Balancer { return kafka.CRC32Balancer{} },
+}
+
+// MapPartitionerString maps a partitioner string to a new kafka.Balancer. The murmur2
+// partitioner matches the default partitioner of the Java producer, whereas the crc32
+// one matches the default partitioner of librdkafka.
+func MapPartitionerString(partitioner string) (kafka.Balancer, error) {
+	if f, ok := partitioner2factory[partitioner]; ok {
+		return f(), nil
+	}
+	return nil, fmt.Errorf("unknown partitioner: %s", partitioner)
+}
+
+// PartitionBalancer assigns the messages to the partition set in the message, e.g. by
+// the processor rendering the partition template, modulo the number of partitions.
+// Messages with the negative partition are assigned by the fallback balancer.
+type PartitionBalancer struct {
+	Fallback kafka.Balancer
+}
+
+func (b *PartitionBalancer) Balance(message kafka.Message, partitions ...int) int {
+	if message.Partition < 0 {
+		return b.Fallback.Balance(message, partitions...)
+	}
+	return partitions[message.Partition%len(partitions)]
+}
@@ -76,3 +76,70 @@ var _ = Describe("MapMechanismString", func() {
 		})
 	})
 })
+
+var _ = Describe("MapRequiredAcksString", func() {
+	When("mapping a known required acks string", func() {
+		It("should return the corresponding kafka.RequiredAcks", func() {
+			acks := []string{"none", "one", "all"}
+			expected := []kafka.RequiredAcks{kafka.RequireNone, kafka.RequireOne, kafka.RequireAll}
+
+			for i, a := range acks {
+				required, err := exporter.MapRequiredAcksString(a)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(required).To(Equal(expected[i]))
+			}
+		})
+	})
+
+	When("mapping an unknown required acks string", func() {
+		It("should return an error", func() {
+			_, err := exporter.MapRequiredAcksString("quorum")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("MapPartitionerString", func() {
+	When("mapping a known partitioner string", func() {
+		It("should return the corresponding kafka.Balancer", func() {
+			partitioners := []string{"least-bytes", "round-robin", "hash", "murmur2", "crc32"}
+			expected := []kafka.Balancer{
+				&kafka.LeastBytes{},
+				&kafka.RoundRobin{},
+				&kafka.Hash{},
+				kafka.Murmur2Balancer{},
+				kafka.CRC32Balancer{},
+			}
+
+			for i, p := range partitioners {
+				balancer, err := exporter.MapPartitionerString(p)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(balancer).To(BeAssignableToTypeOf(expected[i]))
+			}
+		})
+	})
+
+	When("mapping an unknown partitioner string", func() {
+		It("should return an error", func() {
+			balancer, err := exporter.MapPartitionerString("random")
+			Expect(err).To(HaveOccurred())
+			Expect(balancer).To(BeNil())
+		})
+	})
+})
+
+var _ = Describe("PartitionBalancer", func() {
+	balancer := &exporter.PartitionBalancer{Fallback: kafka.Murmur2Balancer{Consistent: true}}
+	partitions := []int{0, 1, 2}
+
+	It("should assign the partition set in the message modulo the number of partitions", func() {
+		Expect(balancer.Balance(kafka.Message{Partition: 1}, partitions...)).To(Equal(1))
+		Expect(balancer.Balance(kafka.Message{Partition: 5}, partitions...)).To(Equal(2))
+	})
+
+	It("should use the fallback balancer for the negative partition", func() {
+		message := kafka.Message{Key: []byte("key"), Partition: -1}
+		expected := kafka.Murmur2Balancer{Consistent: true}.Balance(message, partitions...)
+		Expect(balancer.Balance(message, partitions...)).To(Equal(expected))
+	})
+})
@@ -0,0 +1,60 @@
+package exporter
+
+import (
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+// RetryQueue exposes the retry queue to the tests. The messages are identified by
+// their values.
+type RetryQueue struct {
+	queue *retryQueue
+}
+
+func NewRetryQueue(limit int) *RetryQueue {
+	return &RetryQueue{queue: newRetryQueue(limit)}
+}
+
+// Push queues the message due at the given time. It returns the value of the evicted
+// message, if any.
+func (q *RetryQueue) Push(message kafka.Message, due time.Time) string {
+	if evicted := q.queue.push(message, 1, nil, due); evicted != nil {
+		return string(evicted.message.Value)
+	}
+	return ""
+}
+
+// Hold returns the values of the messages which were not held along with the values of
+// the evicted ones.
+func (q *RetryQueue) Hold(messages []kafka.Message, now time.Time) ([]string, []string) {
+	kept, evicted := q.queue.hold(messages, now)
+
+	var values []string
+	for _, message := range kept {
+		values = append(values, string(message.Value))
+	}
+	return values, retryValues(evicted)
+}
+
+// Take removes up to n messages due at the given time, as if they were written, and
+// returns their values.
+func (q *RetryQueue) Take(now time.Time, n int) []string {
+	entries := q.queue.due(now, n)
+	for _, entry := range entries {
+		q.queue.remove(entry)
+	}
+	return retryValues(entries)
+}
+
+// Drain removes all queued messages and returns their values.
+func (q *RetryQueue) Drain() []string {
+	return retryValues(q.queue.drain())
+}
+
+func retryValues(entries []*retry) []string {
+	var values []string
+	for _, entry := range entries {
+		values = append(values, string(entry.message.Value))
+	}
+	return values
+}
@@ -3,9 +3,11 @@ package exporter
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	log "github.com/raczu/kube2kafka/pkg/logger"
 	"github.com/raczu/kube2kafka/pkg/processor"
+	"github.com/raczu/kube2kafka/pkg/spool"
 	"github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl"
 	"go.uber.org/zap"
@@ -16,14 +18,44 @@ import (
 const (
 	DefaultMaxAttempts = 3
 	DefaultBatchSize   = 16
+	DefaultDialTimeout = 5 * time.Second
+)
+
+var (
+	errRetryQueueFull = errors.New("retry queue is full")
+	errStopped        = errors.New("exporter stopped before the retry")
 )
 
 type Option func(*Exporter)
 
+// DeadLetterFunc is called with the messages dropped by the exporter along with the number
+// of attempts to write them and the reason of the failure.
+type DeadLetterFunc func(message *kafka.Message, attempts int, err error)
+
 type Exporter struct {
 	source *processor.KafkaMessageBuffer
 	writer *kafka.Writer
-	logger *zap.Logger
+	// topic is the default topic for messages without the topic set. It is not
+	// set in the writer, as messages may be routed to different topics.
+	topic string
+	// maxMessageBytes is the maximum size of the message, larger messages are dropped.
+	maxMessageBytes int
+	// spool persists the messages until they are written to Kafka, if set.
+	spool   *spool.Spool
+	retry   RetryPolicy
+	retries *retryQueue
+	// failures is the number of consecutive failures of writing the spooled messages,
+	// whereas failed are the ones written again before reading the next ones. The
+	// messages up to the lastRead offset are acknowledged once all of them are written.
+	failures   int
+	failed     []spool.Entry
+	lastRead   uint64
+	deadLetter DeadLetterFunc
+	// gapMarkers defines whether the gap markers are sent when the messages are lost
+	// in the source buffer, whereas cluster is the name of the cluster set in them.
+	gapMarkers bool
+	cluster    string
+	logger     *zap.Logger
 }
 
 func New(
@@ -34,7 +66,7 @@ func New(
 ) *Exporter {
 	transport := &kafka.Transport{
 		Dial: (&net.Dialer{
-			Timeout: 5 * time.Second,
+			Timeout: DefaultDialTimeout,
 		}).DialContext,
 	}
 
@@ -42,18 +74,25 @@ func New(
 		source: source,
 		writer: &kafka.Writer{
 			Addr:        kafka.TCP(brokers...),
-			Topic:       topic,
 			Balancer:    &kafka.LeastBytes{},
 			MaxAttempts: DefaultMaxAttempts,
 			BatchSize:   DefaultBatchSize,
 			Transport:   transport,
 		},
-		logger: log.New().Named("exporter"),
+		topic:           topic,
+		maxMessageBytes: processor.DefaultMaxMessageBytes,
+		retry:           DefaultRetryPolicy(),
+		logger:          log.New().Named("exporter"),
 	}
 
 	for _, opt := range opts {
 		opt(e)
 	}
+	e.retries = newRetryQueue(e.retry.MaxQueued)
+
+	// The writer rejects the message larger than the batch, thus the batch has to fit
+	// the largest message accepted by the exporter.
+	e.writer.BatchBytes = max(e.writer.BatchBytes, int64(e.maxMessageBytes))
 
 	sublogger := e.logger.Named("writer").Sugar()
 	e.writer.Logger = kafka.LoggerFunc(sublogger.Debugf)
@@ -61,65 +100,367 @@ func New(
 	return e
 }
 
-// write writes the given messages to the Kafka topic. It returns the number of messages
-// successfully written, boolean indicating if the error is fatal and the error itself.
-func (e *Exporter) write(ctx context.Context, messages []kafka.Message) (int, bool, error) {
-	written := len(messages)
+// write writes the given messages to the Kafka topics. It returns the errors of the messages
+// which failed to be written, aligned with the messages, boolean indicating if the error is
+// fatal and the error itself.
+func (e *Exporter) write(
+	ctx context.Context,
+	messages []kafka.Message,
+) (kafka.WriteErrors, bool, error) {
+	for i := range messages {
+		if messages[i].Topic == "" {
+			messages[i].Topic = e.topic
+		}
+	}
 
 	switch err := e.writer.WriteMessages(ctx, messages...).(type) {
 	case nil:
-		return written, false, nil
+		return nil, false, nil
 	case kafka.WriteErrors:
-		for _, werr := range err {
-			if werr != nil {
-				written--
-				// If the error is fatal, return immediately as
-				// the writer will not be able to send anything.
-				if isFatalError(werr) {
-					return 0, true, werr
-				}
+		for i, werr := range err {
+			// If the error is fatal, return immediately as
+			// the writer will not be able to send anything.
+			if werr != nil && isFatalError(werr) &&
+				!isRoutedTopicError(werr, messages[i].Topic, e.topic) {
+				return nil, true, werr
 			}
 		}
-		return written, false, err
+		return err, false, err
 	default:
-		return 0, isFatalError(err), err
+		// The writer looks up the partitions of every topic before writing anything, thus
+		// the unknown routed topic fails the whole batch. Write each topic separately, so
+		// the error concerns only the messages routed to that topic.
+		if topics := groupByTopic(messages); len(topics) > 1 &&
+			errors.Is(err, kafka.UnknownTopicOrPartition) {
+			return e.writeByTopic(ctx, messages, topics)
+		}
+
+		errs := make(kafka.WriteErrors, len(messages))
+		for i := range errs {
+			errs[i] = err
+		}
+		fatal := isFatalError(err) && !isRoutedTopicError(err, messages[0].Topic, e.topic)
+		return errs, fatal, err
 	}
 }
 
-func (e *Exporter) export(ctx context.Context, messages []kafka.Message) error {
-	written, fatal, err := e.write(ctx, messages)
+// writeByTopic writes the messages of each topic separately. The topics hold the indexes
+// of the messages grouped by their topic. It returns the same as write.
+func (e *Exporter) writeByTopic(
+	ctx context.Context,
+	messages []kafka.Message,
+	topics [][]int,
+) (kafka.WriteErrors, bool, error) {
+	var errs kafka.WriteErrors
+	for _, indexes := range topics {
+		batch := make([]kafka.Message, len(indexes))
+		for i, index := range indexes {
+			batch[i] = messages[index]
+		}
+
+		werrs, fatal, err := e.write(ctx, batch)
+		if fatal {
+			return nil, true, err
+		}
+		if werrs == nil {
+			continue
+		}
+
+		if errs == nil {
+			errs = make(kafka.WriteErrors, len(messages))
+		}
+		for i, werr := range werrs {
+			errs[indexes[i]] = werr
+		}
+	}
+
+	if errs == nil {
+		return nil, false, nil
+	}
+	return errs, false, errs
+}
+
+// dropOversized drops the messages exceeding the maximum message size, as a single such
+// message may make the writer reject all messages passed along with it. It returns the
+// indexes of the kept messages.
+func (e *Exporter) dropOversized(messages []kafka.Message) []int {
+	limit := e.maxMessageBytes
+	kept := make([]int, 0, len(messages))
+	for i := range messages {
+		if size := processor.MessageSize(&messages[i]); size > limit {
+			e.logger.Error(
+				"message exceeds the maximum size, dropping message",
+				zap.String("topic", messages[i].Topic),
+				zap.ByteString("key", messages[i].Key),
+				zap.Int("size", size),
+				zap.Int("limit", limit),
+			)
+
+			if e.deadLetter != nil {
+				e.deadLetter(&messages[i], 0, fmt.Errorf(
+					"message size of %d bytes exceeds the limit of %d bytes", size, limit,
+				))
+			}
+			continue
+		}
+		kept = append(kept, i)
+	}
+	return kept
+}
+
+// export writes the messages to Kafka and returns the errors of the messages which
+// failed to be written and can be retried, aligned with the messages, whereas oversized
+// messages are dropped. The error is returned only if it is fatal.
+func (e *Exporter) export(
+	ctx context.Context,
+	messages []kafka.Message,
+) (kafka.WriteErrors, error) {
+	kept := e.dropOversized(messages)
+	if len(kept) == 0 {
+		return nil, nil
+	}
+
+	batch := make([]kafka.Message, len(kept))
+	for i, k := range kept {
+		batch[i] = messages[k]
+	}
+
+	werrs, fatal, err := e.write(ctx, batch)
 	if err != nil {
 		if fatal {
-			return fmt.Errorf("encountered fatal error: %w", err)
+			return nil, fmt.Errorf("encountered fatal error: %w", err)
 		}
-		e.logger.Error("failed to write messages to kafka", zap.Error(err))
+		e.logger.Error(
+			"failed to write messages to kafka",
+			zap.Int("written", len(batch)-werrs.Count()),
+			zap.Int("failed", werrs.Count()),
+			zap.Error(err),
+		)
 	} else {
 		e.logger.Info(
 			"successfully wrote given number of messages to kafka",
-			zap.Int("written", written),
+			zap.Int("written", len(batch)),
 		)
 	}
+
+	if werrs == nil {
+		return nil, nil
+	}
+
+	errs := make(kafka.WriteErrors, len(messages))
+	for i, werr := range werrs {
+		// Retrying the message routed to the unknown topic is pointless, as it is not
+		// going to be created by the exporter.
+		if werr != nil && isRoutedTopicError(werr, batch[i].Topic, e.topic) {
+			e.dropUnroutable(&batch[i], werr)
+			continue
+		}
+		errs[kept[i]] = werr
+	}
+
+	if errs.Count() == 0 {
+		return nil, nil
+	}
+	return errs, nil
+}
+
+// exportNew writes the messages read from the source. Messages with the same key as
+// any message waiting for the retry are queued behind it to preserve their order.
+func (e *Exporter) exportNew(ctx context.Context, messages []kafka.Message) error {
+	now := time.Now()
+	messages, evicted := e.retries.hold(messages, now)
+	for _, entry := range evicted {
+		e.dropEvicted(entry)
+	}
+
+	errs, err := e.export(ctx, messages)
+	if err != nil {
+		return err
+	}
+
+	for i, werr := range errs {
+		if werr != nil {
+			e.scheduleRetry(messages[i], werr, now)
+		}
+	}
+	return nil
+}
+
+// exportRetries writes the messages due to be retried.
+func (e *Exporter) exportRetries(ctx context.Context) error {
+	now := time.Now()
+	entries := e.retries.due(now, e.writer.BatchSize)
+	if len(entries) == 0 {
+		return nil
+	}
+
+	messages := make([]kafka.Message, len(entries))
+	for i, entry := range entries {
+		messages[i] = entry.message
+	}
+
+	errs, err := e.export(ctx, messages)
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		if errs == nil || errs[i] == nil {
+			e.retries.remove(entry)
+			continue
+		}
+
+		entry.attempts++
+		entry.err = errs[i]
+		if entry.attempts > e.retry.MaxRetries {
+			e.retries.remove(entry)
+			e.dropExhausted(&entry.message, entry.attempts, entry.err)
+			continue
+		}
+		entry.due = now.Add(e.retry.Backoff(entry.attempts))
+	}
 	return nil
 }
 
-// Export reads messages from the source buffer and writes them to the Kafka topic.
-// It returns an error in case of encountering a fatal error caused by the misconfiguration
-// of the Kafka cluster or the exporter itself preventing further operation.
+// scheduleRetry queues the message which failed to be written for the first time.
+func (e *Exporter) scheduleRetry(message kafka.Message, err error, now time.Time) {
+	if e.retry.MaxRetries == 0 {
+		e.dropExhausted(&message, 1, err)
+		return
+	}
+
+	if evicted := e.retries.push(message, 1, err, now.Add(e.retry.Backoff(1))); evicted != nil {
+		e.dropEvicted(evicted)
+	}
+}
+
+// dropExhausted drops the message which exhausted the retry budget, handing it over
+// to the dead-letter function if set.
+func (e *Exporter) dropExhausted(message *kafka.Message, attempts int, err error) {
+	e.logger.Error(
+		"message exhausted the retry budget, dropping message",
+		zap.String("topic", message.Topic),
+		zap.ByteString("key", message.Key),
+		zap.Int("attempts", attempts),
+		zap.Error(err),
+	)
+
+	if e.deadLetter != nil {
+		e.deadLetter(message, attempts, err)
+	}
+}
+
+// dropUnroutable drops the message routed to the topic which does not exist, handing it
+// over to the dead-letter function if set.
+func (e *Exporter) dropUnroutable(message *kafka.Message, err error) {
+	e.logger.Error(
+		"message routed to unknown topic, dropping message",
+		zap.String("topic", message.Topic),
+		zap.ByteString("key", message.Key),
+		zap.Error(err),
+	)
+
+	if e.deadLetter != nil {
+		e.deadLetter(message, 1, err)
+	}
+}
+
+// dropEvicted drops the message evicted from the full retry queue, handing it over to
+// the dead-letter function if set.
+func (e *Exporter) dropEvicted(entry *retry) {
+	err := errRetryQueueFull
+	if entry.err != nil {
+		err = fmt.Errorf("%w, last error: %w", errRetryQueueFull, entry.err)
+	}
+
+	e.logger.Error(
+		"retry queue is full, dropping the oldest message",
+		zap.String("topic", entry.message.Topic),
+		zap.ByteString("key", entry.message.Key),
+		zap.Int("attempts", entry.attempts),
+		zap.Error(err),
+	)
+
+	if e.deadLetter != nil {
+		e.deadLetter(&entry.message, entry.attempts, err)
+	}
+}
+
+// dropQueued drops the messages waiting for the retry, as they are lost once the exporter
+// stops, handing them over to the dead-letter function if set.
+func (e *Exporter) dropQueued() {
+	entries := e.retries.drain()
+	if len(entries) == 0 {
+		return
+	}
+	e.logger.Warn("dropping messages waiting for the retry", zap.Int("dropped", len(entries)))
+
+	if e.deadLetter == nil {
+		return
+	}
+
+	for _, entry := range entries {
+		err := errStopped
+		if entry.err != nil {
+			err = fmt.Errorf("%w, last error: %w", errStopped, entry.err)
+		}
+		e.deadLetter(&entry.message, entry.attempts, err)
+	}
+}
+
+// Export reads messages from the source buffer and writes them to the Kafka topics.
+// Messages which failed to be written are retried with the backoff according to the
+// retry policy. It returns an error in case of encountering a fatal error caused by
+// the misconfiguration of the Kafka cluster or the exporter itself preventing further
+// operation.
 func (e *Exporter) Export(ctx context.Context) error {
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
+	if e.spool != nil {
+		return e.exportSpooled(ctx)
+	}
+
+	// The timer wakes up the exporter when the earliest message waiting for the retry
+	// is due. It is created stopped and reset on each iteration.
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
 
 	for {
+		var due <-chan time.Time
+		if next, ok := e.retries.next(); ok {
+			resetTimer(timer, time.Until(next))
+			due = timer.C
+		}
+
 		select {
 		case <-ctx.Done():
+			e.dropQueued()
 			if err := e.writer.Close(); err != nil {
 				return fmt.Errorf("failed to close kafka writer in exporter: %w", err)
 			}
 			return nil
-		case <-ticker.C:
-			if e.source.Size() > 0 {
+		case <-due:
+			if err := e.exportRetries(ctx); err != nil {
+				return err
+			}
+		case <-e.source.NotEmpty():
+			// Export the messages in batches until the source is drained. The messages due
+			// to be retried are written between the batches, so they are not starved when
+			// the source is refilled faster than drained.
+			for ctx.Err() == nil {
+				if err := e.exportRetries(ctx); err != nil {
+					return err
+				}
+
 				messages := tryReadUpTo(e.source, e.writer.BatchSize)
-				if err := e.export(ctx, messages); err != nil {
+				if len(messages) == 0 {
+					break
+				}
+
+				if gap := e.reportLoss(); gap != nil {
+					messages = append([]kafka.Message{*gap}, messages...)
+				}
+
+				if err := e.exportNew(ctx, messages); err != nil {
 					return err
 				}
 			}
@@ -127,19 +468,57 @@ func (e *Exporter) Export(ctx context.Context) error {
 	}
 }
 
+// reportLoss logs the messages lost in the source buffer due to the overflow. It returns
+// the gap marker describing them, which should be sent ahead of the messages read after
+// the loss, if enabled.
+func (e *Exporter) reportLoss() *kafka.Message {
+	loss := e.source.TakeLoss()
+	if loss.Count == 0 {
+		return nil
+	}
+
+	e.logger.Warn("messages lost due to the buffer overflow",
+		zap.Int("lost", loss.Count),
+		zap.Time("from", loss.From),
+		zap.Time("to", loss.To),
+	)
+
+	if !e.gapMarkers {
+		return nil
+	}
+
+	gap, err := processor.NewGapMessage(e.cluster, loss)
+	if err != nil {
+		e.logger.Error("failed to create gap marker", zap.Error(err))
+		return nil
+	}
+	return gap
+}
+
 // UseTLS configures the exporter to use TLS for communication with the Kafka brokers.
 func UseTLS(config *tls.Config) Option {
 	return func(e *Exporter) {
-		transport := e.writer.Transport.(*kafka.Transport)
-		transport.TLS = config
+		if transport, ok := e.writer.Transport.(*kafka.Transport); ok {
+			transport.TLS = config
+		}
 	}
 }
 
 // UseSASL configures the exporter to use SASL for authentication with the Kafka brokers.
 func UseSASL(mechanism sasl.Mechanism) Option {
 	return func(e *Exporter) {
-		transport := e.writer.Transport.(*kafka.Transport)
-		transport.SASL = mechanism
+		if transport, ok := e.writer.Transport.(*kafka.Transport); ok {
+			transport.SASL = mechanism
+		}
+	}
+}
+
+// WithTransport replaces the transport used to communicate with the Kafka brokers. The TLS,
+// SASL and dial timeout options apply only to the default kafka.Transport, thus they have
+// no effect on the custom one.
+func WithTransport(transport kafka.RoundTripper) Option {
+	return func(e *Exporter) {
+		e.writer.Transport = transport
 	}
 }
 
@@ -150,6 +529,117 @@ func WithCompression(codec kafka.Compression) Option {
 	}
 }
 
+// WithBalancer configures the exporter to use the specified balancer to distribute
+// messages across the partitions of the topic.
+func WithBalancer(balancer kafka.Balancer) Option {
+	return func(e *Exporter) {
+		e.writer.Balancer = balancer
+	}
+}
+
+// WithMaxMessageBytes sets the maximum size of the message, which is also the minimum
+// size of the batch sent to the Kafka brokers. Larger messages are dropped.
+func WithMaxMessageBytes(n int) Option {
+	return func(e *Exporter) {
+		e.maxMessageBytes = n
+	}
+}
+
+// WithBatchSize sets the maximum number of messages in the batch sent to the partition.
+func WithBatchSize(n int) Option {
+	return func(e *Exporter) {
+		e.writer.BatchSize = n
+	}
+}
+
+// WithBatchBytes sets the maximum size of the batch sent to the partition. It is raised
+// to the maximum size of the message if lower, so the largest message still fits.
+func WithBatchBytes(n int64) Option {
+	return func(e *Exporter) {
+		e.writer.BatchBytes = n
+	}
+}
+
+// WithBatchTimeout sets the time after which the incomplete batch is sent.
+func WithBatchTimeout(timeout time.Duration) Option {
+	return func(e *Exporter) {
+		e.writer.BatchTimeout = timeout
+	}
+}
+
+// WithMaxAttempts sets the number of attempts the writer makes to deliver the batch,
+// before the messages are handed over to the retry policy.
+func WithMaxAttempts(n int) Option {
+	return func(e *Exporter) {
+		e.writer.MaxAttempts = n
+	}
+}
+
+// WithRequiredAcks sets the number of acknowledgements from the partition replicas
+// required before the messages are considered written.
+func WithRequiredAcks(acks kafka.RequiredAcks) Option {
+	return func(e *Exporter) {
+		e.writer.RequiredAcks = acks
+	}
+}
+
+// WithDialTimeout sets the timeout of establishing the connection to the Kafka brokers.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(e *Exporter) {
+		if transport, ok := e.writer.Transport.(*kafka.Transport); ok {
+			transport.Dial = (&net.Dialer{
+				Timeout: timeout,
+			}).DialContext
+		}
+	}
+}
+
+// WithTimeouts sets the timeouts of the read and write operations performed by the writer.
+func WithTimeouts(read, write time.Duration) Option {
+	return func(e *Exporter) {
+		e.writer.ReadTimeout = read
+		e.writer.WriteTimeout = write
+	}
+}
+
+// WithSpool configures the exporter to persist the messages in the spool and read them
+// from it, acknowledging them only once written to Kafka. Unless other required acks are
+// set, the write has to be acknowledged by the full ISR, so the rejected messages are not
+// acknowledged unnoticed. The spool is not closed by the exporter.
+func WithSpool(spool *spool.Spool) Option {
+	return func(e *Exporter) {
+		e.spool = spool
+		if e.writer.RequiredAcks == kafka.RequireNone {
+			e.writer.RequiredAcks = kafka.RequireAll
+		}
+	}
+}
+
+// WithRetry sets the policy of retrying the messages which failed to be written.
+func WithRetry(policy RetryPolicy) Option {
+	return func(e *Exporter) {
+		e.retry = policy
+	}
+}
+
+// WithDeadLetter sets the function receiving the messages which are either oversized,
+// routed to the unknown topic, exhausted the retry budget or did not fit in the retry
+// queue. By default, such messages are only logged and dropped.
+func WithDeadLetter(fn DeadLetterFunc) Option {
+	return func(e *Exporter) {
+		e.deadLetter = fn
+	}
+}
+
+// WithGapMarkers enables the gap markers sent to the default topic when the messages
+// are lost in the source buffer due to the overflow.
+func WithGapMarkers(cluster string) Option {
+	return func(e *Exporter) {
+		e.gapMarkers = true
+		e.cluster = cluster
+	}
+}
+
 // WithLogger sets the logger for the exporter.
 func WithLogger(logger *zap.Logger) Option {
 	return func(e *Exporter) {
@@ -0,0 +1,90 @@
+package exporter_test
+
+import (
+	"context"
+	"github.com/raczu/kube2kafka/internal/kafkatest"
+	"github.com/raczu/kube2kafka/pkg/exporter"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/kube/watcher"
+	log "github.com/raczu/kube2kafka/pkg/logger"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// BenchmarkExporter measures the throughput of the whole pipeline from the event buffer,
+// through the processor, to the exporter writing the messages with the stub transport.
+func BenchmarkExporter(b *testing.B) {
+	const capacity = 128
+
+	opts := log.Options{Output: io.Discard}
+	logger := log.New(log.UseOptions(&opts))
+
+	source := watcher.NewEventBuffer(capacity)
+	// The output holds all messages, so none of them is overwritten if the exporter lags.
+	output := processor.NewKafkaMessageBuffer(b.N)
+	proc := processor.New(
+		source,
+		processor.WriteTo(output),
+		processor.WithLogger(logger),
+	)
+
+	transport := &kafkatest.Transport{}
+	exp := exporter.New(
+		output,
+		"k8s-events",
+		[]string{"localhost:9092"},
+		exporter.WithTransport(transport),
+		// The incomplete batch at the end of the drained buffer is sent without waiting
+		// for the default batch timeout, which would dominate the measurement.
+		exporter.WithBatchTimeout(time.Millisecond),
+		exporter.WithLogger(logger),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go proc.Process(ctx)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- exp.Export(ctx)
+	}()
+
+	event := &kube.EnhancedEvent{
+		Event: corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: corev1.NamespaceDefault,
+				Name:      "nginx.17c3b0d6d5c0b1a2",
+			},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "nginx"},
+			Reason:         "BackOff",
+			Message:        "Back-off restarting failed container",
+			Type:           "Warning",
+		},
+		ClusterName: "dev.kube2kafka.local",
+	}
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		// Wait for the processor to keep up, so no event is overwritten.
+		for source.Size() == capacity {
+			runtime.Gosched()
+		}
+		source.Write(event)
+	}
+
+	for transport.Produced() < int64(b.N) {
+		select {
+		case err := <-errs:
+			b.Fatalf("exporter stopped: %v", err)
+		default:
+			runtime.Gosched()
+		}
+	}
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "events/s")
+}
@@ -3,15 +3,21 @@ package exporter_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/raczu/kube2kafka/internal/kafkatest"
 	"github.com/raczu/kube2kafka/pkg/exporter"
 	log "github.com/raczu/kube2kafka/pkg/logger"
 	"github.com/raczu/kube2kafka/pkg/processor"
+	"github.com/raczu/kube2kafka/pkg/spool"
 	"github.com/segmentio/kafka-go"
 	"go.uber.org/zap"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -52,6 +58,545 @@ var _ = Describe("Exporter", func() {
 			})
 		})
 
+		Context("and the brokers are unreachable", func() {
+			It("should drop the message once it exhausts the retry budget", func() {
+				output := gbytes.NewBuffer()
+				opts := log.Options{Output: output}
+
+				exp = exporter.New(
+					source,
+					uuid.New().String(),
+					[]string{"127.0.0.1:1"},
+					exporter.WithLogger(log.New(log.UseOptions(&opts))),
+					exporter.WithRetry(exporter.RetryPolicy{
+						MaxRetries:     1,
+						InitialBackoff: 10 * time.Millisecond,
+						MaxBackoff:     10 * time.Millisecond,
+					}),
+				)
+				source.Write(&kafka.Message{Key: []byte("key"), Value: []byte("value")})
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				go func() {
+					defer GinkgoRecover()
+					defer cancel()
+					Eventually(output, 4*time.Second).Should(gbytes.Say(
+						`message exhausted the retry budget.*"attempts":2`,
+					))
+				}()
+
+				err := exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should hand over the dropped messages to the dead-letter function", func() {
+				var (
+					mu      sync.Mutex
+					dropped []int
+				)
+				deadLetter := func(message *kafka.Message, attempts int, err error) {
+					mu.Lock()
+					defer mu.Unlock()
+					Expect(err).To(HaveOccurred())
+					dropped = append(dropped, attempts)
+				}
+
+				exp = exporter.New(
+					source,
+					uuid.New().String(),
+					[]string{"127.0.0.1:1"},
+					exporter.WithLogger(logger),
+					exporter.WithMaxMessageBytes(128),
+					exporter.WithDeadLetter(deadLetter),
+					exporter.WithRetry(exporter.RetryPolicy{
+						MaxRetries:     1,
+						InitialBackoff: 10 * time.Millisecond,
+						MaxBackoff:     10 * time.Millisecond,
+					}),
+				)
+				source.Write(&kafka.Message{Key: []byte("key"), Value: []byte("value")})
+				source.Write(&kafka.Message{Value: make([]byte, 256)})
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				go func() {
+					defer GinkgoRecover()
+					defer cancel()
+					Eventually(func() []int {
+						mu.Lock()
+						defer mu.Unlock()
+						return append([]int(nil), dropped...)
+					}, 4*time.Second).Should(ConsistOf(0, 2))
+				}()
+
+				err := exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should keep the spooled message until it is written", func() {
+				dir := GinkgoT().TempDir()
+				spooled, err := spool.Open(dir, spool.WithLogger(logger))
+				Expect(err).NotTo(HaveOccurred())
+
+				exp = exporter.New(
+					source,
+					uuid.New().String(),
+					[]string{"127.0.0.1:1"},
+					exporter.WithLogger(logger),
+					exporter.WithSpool(spooled),
+					// The backoff outlasts the export, so the message does not
+					// exhaust the retry budget and stays in the spool.
+					exporter.WithRetry(exporter.RetryPolicy{
+						MaxRetries:     1,
+						InitialBackoff: time.Minute,
+						MaxBackoff:     time.Minute,
+					}),
+				)
+				source.Write(&kafka.Message{Key: []byte("key"), Value: []byte("value")})
+
+				ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				defer cancel()
+
+				err = exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(spooled.Close()).To(Succeed())
+
+				// The message is recovered, as it was not acknowledged.
+				spooled, err = spool.Open(dir, spool.WithLogger(logger))
+				Expect(err).NotTo(HaveOccurred())
+				defer spooled.Close()
+				Expect(spooled.Pending()).To(Equal(uint64(1)))
+			})
+		})
+
+		Context("and the messages were lost in the buffer", func() {
+			It("should send the gap marker ahead of the remaining messages", func() {
+				transport := &kafkatest.Transport{Keep: true}
+				exp = exporter.New(
+					source,
+					"k8s-events",
+					[]string{"localhost:9092"},
+					exporter.WithLogger(logger),
+					exporter.WithTransport(transport),
+					exporter.WithBatchTimeout(10*time.Millisecond),
+					exporter.WithGapMarkers("dev.kube2kafka.local"),
+				)
+
+				// The buffer holds 8 messages, thus the oldest 2 are overwritten.
+				for i := 0; i < 10; i++ {
+					source.Write(&kafka.Message{Value: []byte("value")})
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				go func() {
+					defer GinkgoRecover()
+					defer cancel()
+					Eventually(transport.Produced, 4*time.Second).Should(Equal(int64(9)))
+				}()
+
+				err := exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				messages := transport.Messages()
+				Expect(messages).To(HaveLen(9))
+				Expect(messages[0].Topic).To(Equal("k8s-events"))
+				Expect(messages[0].Headers).To(ContainElement(kafka.Header{
+					Key:   processor.TypeHeader,
+					Value: []byte(processor.GapType),
+				}))
+
+				var gap processor.Gap
+				err = json.Unmarshal(messages[0].Value, &gap)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(gap.Cluster).To(Equal("dev.kube2kafka.local"))
+				Expect(gap.Lost).To(Equal(2))
+			})
+		})
+
+		Context("and the broker rejects the messages of some topic", func() {
+			var (
+				transport *kafkatest.Transport
+			)
+
+			BeforeEach(func() {
+				transport = &kafkatest.Transport{Keep: true}
+				transport.Reject("broken", kafka.NotEnoughReplicas)
+			})
+
+			It("should retry the messages while the source keeps being refilled", func() {
+				exp = exporter.New(
+					source,
+					"k8s-events",
+					[]string{"localhost:9092"},
+					exporter.WithLogger(logger),
+					exporter.WithTransport(transport),
+					exporter.WithBatchTimeout(time.Millisecond),
+					exporter.WithMaxAttempts(1),
+					// The rejection is reported only if the write is acknowledged.
+					exporter.WithRequiredAcks(kafka.RequireAll),
+					exporter.WithRetry(exporter.RetryPolicy{
+						MaxRetries:     100,
+						InitialBackoff: 10 * time.Millisecond,
+						MaxBackoff:     10 * time.Millisecond,
+					}),
+				)
+				source.Write(&kafka.Message{Topic: "broken", Value: []byte("retried")})
+				// The source is refilled with each write, so it never becomes empty.
+				transport.OnProduce = func() {
+					source.Write(&kafka.Message{Value: []byte("value")})
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				go func() {
+					defer GinkgoRecover()
+					defer cancel()
+					time.Sleep(50 * time.Millisecond)
+					transport.Reject("broken", 0)
+					Eventually(func() []string {
+						var values []string
+						for _, message := range transport.Messages() {
+							if message.Topic == "broken" {
+								values = append(values, string(message.Value))
+							}
+						}
+						return values
+					}, 4*time.Second).Should(Equal([]string{"retried"}))
+				}()
+
+				err := exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should drop the oldest messages once the retry queue is full", func() {
+				var (
+					mu      sync.Mutex
+					dropped []string
+					errs    []error
+				)
+				deadLetter := func(message *kafka.Message, attempts int, err error) {
+					mu.Lock()
+					defer mu.Unlock()
+					Expect(attempts).To(Equal(1))
+					dropped = append(dropped, string(message.Value))
+					errs = append(errs, err)
+				}
+
+				exp = exporter.New(
+					source,
+					"k8s-events",
+					[]string{"localhost:9092"},
+					exporter.WithLogger(logger),
+					exporter.WithTransport(transport),
+					exporter.WithBatchTimeout(10*time.Millisecond),
+					exporter.WithMaxAttempts(1),
+					// The rejection is reported only if the write is acknowledged.
+					exporter.WithRequiredAcks(kafka.RequireAll),
+					exporter.WithDeadLetter(deadLetter),
+					exporter.WithRetry(exporter.RetryPolicy{
+						MaxRetries:     1,
+						InitialBackoff: time.Minute,
+						MaxBackoff:     time.Minute,
+						MaxQueued:      2,
+					}),
+				)
+				for i := 0; i < 4; i++ {
+					source.Write(&kafka.Message{Topic: "broken", Value: []byte(strconv.Itoa(i))})
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				go func() {
+					defer GinkgoRecover()
+					defer cancel()
+					Eventually(func() []string {
+						mu.Lock()
+						defer mu.Unlock()
+						return append([]string(nil), dropped...)
+					}, 4*time.Second).Should(Equal([]string{"0", "1"}))
+				}()
+
+				err := exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				// The messages left in the queue are handed over once stopped.
+				Expect(dropped).To(Equal([]string{"0", "1", "2", "3"}))
+				Expect(errs[0]).To(MatchError(ContainSubstring("retry queue is full")))
+				Expect(errs[1]).To(MatchError(ContainSubstring("retry queue is full")))
+				Expect(errs[2]).To(MatchError(ContainSubstring("exporter stopped")))
+				Expect(errs[3]).To(MatchError(ContainSubstring("exporter stopped")))
+			})
+
+			It("should hand over the messages waiting for the retry once stopped", func() {
+				var (
+					attempts []int
+					errs     []error
+				)
+				deadLetter := func(message *kafka.Message, n int, err error) {
+					attempts = append(attempts, n)
+					errs = append(errs, err)
+				}
+
+				exp = exporter.New(
+					source,
+					"k8s-events",
+					[]string{"localhost:9092"},
+					exporter.WithLogger(logger),
+					exporter.WithTransport(transport),
+					exporter.WithBatchTimeout(10*time.Millisecond),
+					exporter.WithMaxAttempts(1),
+					// The rejection is reported only if the write is acknowledged.
+					exporter.WithRequiredAcks(kafka.RequireAll),
+					exporter.WithDeadLetter(deadLetter),
+					// The backoff outlasts the export, so the message waits for the retry.
+					exporter.WithRetry(exporter.RetryPolicy{
+						MaxRetries:     1,
+						InitialBackoff: time.Minute,
+						MaxBackoff:     time.Minute,
+					}),
+				)
+				source.Write(&kafka.Message{Topic: "broken", Value: []byte("value")})
+
+				ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+				defer cancel()
+
+				err := exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(attempts).To(Equal([]int{1}))
+				Expect(errs[0]).To(MatchError(kafka.NotEnoughReplicas))
+				Expect(errs[0]).To(MatchError(ContainSubstring("exporter stopped")))
+			})
+
+			It("should write again only the spooled messages which were rejected", func() {
+				spooled, err := spool.Open(GinkgoT().TempDir(), spool.WithLogger(logger))
+				Expect(err).NotTo(HaveOccurred())
+				defer spooled.Close()
+
+				exp = exporter.New(
+					source,
+					"k8s-events",
+					[]string{"localhost:9092"},
+					exporter.WithLogger(logger),
+					exporter.WithTransport(transport),
+					exporter.WithBatchTimeout(10*time.Millisecond),
+					exporter.WithMaxAttempts(1),
+					exporter.WithSpool(spooled),
+					exporter.WithRetry(exporter.RetryPolicy{
+						MaxRetries:     100,
+						InitialBackoff: 10 * time.Millisecond,
+						MaxBackoff:     10 * time.Millisecond,
+					}),
+				)
+				source.Write(&kafka.Message{Value: []byte("0")})
+				source.Write(&kafka.Message{Topic: "broken", Value: []byte("1")})
+				source.Write(&kafka.Message{Value: []byte("2")})
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				go func() {
+					defer GinkgoRecover()
+					defer cancel()
+					// Only the message written ahead of the rejected one is acknowledged.
+					Eventually(transport.Produced, 2*time.Second).Should(Equal(int64(2)))
+					Eventually(spooled.Pending, 2*time.Second).Should(Equal(uint64(2)))
+
+					transport.Reject("broken", 0)
+					Eventually(spooled.Pending, 2*time.Second).Should(BeZero())
+					Expect(transport.Produced()).To(Equal(int64(3)))
+				}()
+
+				err = exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should keep the rejected spooled messages out of the retry queue", func() {
+				spooled, err := spool.Open(GinkgoT().TempDir(), spool.WithLogger(logger))
+				Expect(err).NotTo(HaveOccurred())
+				defer spooled.Close()
+
+				var dropped []string
+				deadLetter := func(message *kafka.Message, attempts int, err error) {
+					dropped = append(dropped, string(message.Value))
+				}
+
+				exp = exporter.New(
+					source,
+					"k8s-events",
+					[]string{"localhost:9092"},
+					exporter.WithLogger(logger),
+					exporter.WithTransport(transport),
+					exporter.WithBatchTimeout(10*time.Millisecond),
+					exporter.WithMaxAttempts(1),
+					exporter.WithSpool(spooled),
+					exporter.WithDeadLetter(deadLetter),
+					// The spooled messages are not evicted once the retry queue is full.
+					exporter.WithRetry(exporter.RetryPolicy{
+						MaxRetries:     100,
+						InitialBackoff: 10 * time.Millisecond,
+						MaxBackoff:     10 * time.Millisecond,
+						MaxQueued:      1,
+					}),
+				)
+				for i := 0; i < 3; i++ {
+					source.Write(&kafka.Message{
+						Topic: "broken",
+						Key:   []byte("key"),
+						Value: []byte(strconv.Itoa(i)),
+					})
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				go func() {
+					defer GinkgoRecover()
+					defer cancel()
+					Eventually(spooled.Pending, 2*time.Second).Should(Equal(uint64(3)))
+
+					// The message spooled later is written only after the rejected ones.
+					source.Write(&kafka.Message{
+						Topic: "broken",
+						Key:   []byte("key"),
+						Value: []byte("3"),
+					})
+					Eventually(spooled.Pending, 2*time.Second).Should(Equal(uint64(4)))
+
+					transport.Reject("broken", 0)
+					Eventually(spooled.Pending, 2*time.Second).Should(BeZero())
+				}()
+
+				err = exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(dropped).To(BeEmpty())
+
+				var values []string
+				for _, message := range transport.Messages() {
+					values = append(values, string(message.Value))
+				}
+				Expect(values).To(Equal([]string{"0", "1", "2", "3"}))
+			})
+		})
+
+		Context("and the messages are routed to the topic which does not exist", func() {
+			var (
+				transport *kafkatest.Transport
+				mu        sync.Mutex
+				dropped   []string
+				errs      []error
+			)
+
+			BeforeEach(func() {
+				transport = &kafkatest.Transport{Keep: true}
+				transport.Delete("k8s-events-team-a")
+				dropped, errs = nil, nil
+			})
+
+			deadLetter := func(message *kafka.Message, attempts int, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				Expect(attempts).To(Equal(1))
+				dropped = append(dropped, string(message.Value))
+				errs = append(errs, err)
+			}
+
+			It("should hand over the routed messages and export the rest of the batch", func() {
+				exp = exporter.New(
+					source,
+					"k8s-events",
+					[]string{"localhost:9092"},
+					exporter.WithLogger(logger),
+					exporter.WithTransport(transport),
+					exporter.WithBatchTimeout(10*time.Millisecond),
+					exporter.WithDeadLetter(deadLetter),
+				)
+				source.Write(&kafka.Message{Value: []byte("0")})
+				source.Write(&kafka.Message{Topic: "k8s-events-team-a", Value: []byte("1")})
+				source.Write(&kafka.Message{Value: []byte("2")})
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				go func() {
+					defer GinkgoRecover()
+					defer cancel()
+					Eventually(transport.Produced, 4*time.Second).Should(Equal(int64(2)))
+				}()
+
+				err := exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				var values []string
+				for _, message := range transport.Messages() {
+					Expect(message.Topic).To(Equal("k8s-events"))
+					values = append(values, string(message.Value))
+				}
+				Expect(values).To(ConsistOf("0", "2"))
+				Expect(dropped).To(Equal([]string{"1"}))
+				Expect(errs[0]).To(MatchError(kafka.UnknownTopicOrPartition))
+			})
+
+			It("should acknowledge the spooled messages once handed over", func() {
+				spooled, err := spool.Open(GinkgoT().TempDir(), spool.WithLogger(logger))
+				Expect(err).NotTo(HaveOccurred())
+				defer spooled.Close()
+
+				exp = exporter.New(
+					source,
+					"k8s-events",
+					[]string{"localhost:9092"},
+					exporter.WithLogger(logger),
+					exporter.WithTransport(transport),
+					exporter.WithBatchTimeout(10*time.Millisecond),
+					exporter.WithSpool(spooled),
+					exporter.WithDeadLetter(deadLetter),
+				)
+				source.Write(&kafka.Message{Topic: "k8s-events-team-a", Value: []byte("0")})
+				source.Write(&kafka.Message{Value: []byte("1")})
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				go func() {
+					defer GinkgoRecover()
+					defer cancel()
+					Eventually(transport.Produced, 2*time.Second).Should(Equal(int64(1)))
+					Eventually(spooled.Pending, 2*time.Second).Should(BeZero())
+				}()
+
+				err = exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(dropped).To(Equal([]string{"0"}))
+			})
+
+			It("should exit with an error if the default topic does not exist", func() {
+				transport.Delete("k8s-events")
+				exp = exporter.New(
+					source,
+					"k8s-events",
+					[]string{"localhost:9092"},
+					exporter.WithLogger(logger),
+					exporter.WithTransport(transport),
+					exporter.WithDeadLetter(deadLetter),
+				)
+				source.Write(&kafka.Message{Topic: "k8s-events-team-b", Value: []byte("0")})
+				source.Write(&kafka.Message{Value: []byte("1")})
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				err := exp.Export(ctx)
+				Expect(err).To(MatchError(kafka.UnknownTopicOrPartition))
+			})
+		})
+
 		Context("and the topic exists", func() {
 			var (
 				admin *kafka.Client
@@ -182,6 +727,140 @@ var _ = Describe("Exporter", func() {
 					Expect(rtime).To(BeTemporally("~", received[0].Time, time.Millisecond))
 				}
 			})
+
+			It("should drop oversized message and export the rest of the batch", func() {
+				exp = exporter.New(
+					source,
+					topic,
+					brokers,
+					exporter.WithLogger(logger),
+					exporter.WithMaxMessageBytes(1024),
+				)
+				source.Write(&kafka.Message{Key: []byte("key-a"), Value: make([]byte, 2048)})
+				message := &kafka.Message{Key: []byte("key-b"), Value: []byte("value-b")}
+				source.Write(message)
+
+				r := kafka.NewReader(kafka.ReaderConfig{
+					Brokers: brokers,
+					Topic:   topic,
+				})
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				done := make(chan struct{})
+				var received kafka.Message
+				var rerr error
+
+				go func() {
+					defer close(done)
+					received, rerr = r.ReadMessage(ctx)
+					cancel()
+				}()
+
+				err := exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				<-done
+				Expect(rerr).NotTo(HaveOccurred())
+				Expect(received.Key).To(Equal(message.Key))
+				Expect(received.Value).To(Equal(message.Value))
+			})
+
+			It("should export spooled message and acknowledge it", func() {
+				spooled, err := spool.Open(GinkgoT().TempDir(), spool.WithLogger(logger))
+				Expect(err).NotTo(HaveOccurred())
+				defer spooled.Close()
+
+				exp = exporter.New(
+					source,
+					topic,
+					brokers,
+					exporter.WithLogger(logger),
+					exporter.WithSpool(spooled),
+				)
+				message := &kafka.Message{Key: []byte("key"), Value: []byte("value")}
+				source.Write(message)
+
+				r := kafka.NewReader(kafka.ReaderConfig{
+					Brokers: brokers,
+					Topic:   topic,
+				})
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				done := make(chan struct{})
+				var received kafka.Message
+				var rerr error
+
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					received, rerr = r.ReadMessage(ctx)
+					Eventually(spooled.Pending).Should(BeZero())
+					cancel()
+				}()
+
+				err = exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				<-done
+				Expect(rerr).NotTo(HaveOccurred())
+				Expect(received.Value).To(Equal(message.Value))
+				Expect(spooled.Pending()).To(BeZero())
+			})
+
+			It("should export message to the topic set in the message", func() {
+				routed := uuid.New().String()
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				_, err := admin.CreateTopics(ctx, &kafka.CreateTopicsRequest{
+					Topics: []kafka.TopicConfig{
+						{
+							Topic:             routed,
+							NumPartitions:     1,
+							ReplicationFactor: 1,
+						},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				defer func() {
+					_, _ = admin.DeleteTopics(context.Background(), &kafka.DeleteTopicsRequest{
+						Topics: []string{routed},
+					})
+				}()
+
+				message := &kafka.Message{
+					Topic: routed,
+					Key:   []byte("key"),
+					Value: []byte("value"),
+				}
+				source.Write(message)
+
+				r := kafka.NewReader(kafka.ReaderConfig{
+					Brokers: brokers,
+					Topic:   routed,
+				})
+
+				done := make(chan struct{})
+				var received kafka.Message
+				var rerr error
+
+				go func() {
+					defer close(done)
+					received, rerr = r.ReadMessage(ctx)
+					cancel()
+				}()
+
+				err = exp.Export(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				<-done
+				Expect(rerr).NotTo(HaveOccurred())
+				Expect(received.Topic).To(Equal(routed))
+				Expect(received.Value).To(Equal(message.Value))
+			})
 		})
 	})
 })
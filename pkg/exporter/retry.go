@@ -0,0 +1,203 @@
+package exporter
+
+import (
+	"github.com/segmentio/kafka-go"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	DefaultMaxRetries     = 5
+	DefaultInitialBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff     = 10 * time.Second
+	DefaultMaxQueued      = 1024
+)
+
+// RetryPolicy defines how many times and how often the messages which failed to be
+// written are retried. MaxRetries of zero disables the retries. MaxQueued limits the
+// messages waiting for the retry, beyond which the oldest ones are dropped, and falls
+// back to the DefaultMaxQueued if not positive.
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	MaxQueued      int
+}
+
+// DefaultRetryPolicy returns the policy used by the exporter by default.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:     DefaultMaxRetries,
+		InitialBackoff: DefaultInitialBackoff,
+		MaxBackoff:     DefaultMaxBackoff,
+		MaxQueued:      DefaultMaxQueued,
+	}
+}
+
+// Backoff returns the delay before the retry with the given number, starting from 1.
+// The delay doubles with each retry up to the MaxBackoff, while its random half is
+// dropped to spread the retries of the messages failed at once.
+func (p *RetryPolicy) Backoff(retry int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < retry && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxBackoff)
+
+	if half := int64(delay / 2); half > 0 {
+		return time.Duration(half + rand.Int64N(half+1))
+	}
+	return delay
+}
+
+// retry is the message waiting to be written again along with the error of the
+// last attempt, if any.
+type retry struct {
+	message  kafka.Message
+	attempts int
+	err      error
+	due      time.Time
+}
+
+// retryQueue holds the messages waiting to be written again in the order they failed. To
+// preserve the order of the messages with the same key, newer messages are queued behind
+// the held ones and written only after them. Once the queue holds the limit of messages,
+// the oldest one is evicted to make room for the next one.
+type retryQueue struct {
+	entries []*retry
+	// held counts the queued messages per ordering key.
+	held  map[string]int
+	limit int
+}
+
+func newRetryQueue(limit int) *retryQueue {
+	if limit <= 0 {
+		limit = DefaultMaxQueued
+	}
+	return &retryQueue{held: make(map[string]int), limit: limit}
+}
+
+// orderingKey returns the key of the message the order is preserved for, which is
+// empty for the messages without the key.
+func orderingKey(message *kafka.Message) string {
+	if message.Key == nil {
+		return ""
+	}
+	return message.Topic + "/" + string(message.Key)
+}
+
+// push queues the message. It returns the oldest message evicted to make room for it,
+// if the queue is full.
+func (q *retryQueue) push(
+	message kafka.Message,
+	attempts int,
+	err error,
+	due time.Time,
+) *retry {
+	var evicted *retry
+	if len(q.entries) >= q.limit {
+		evicted = q.entries[0]
+		q.remove(evicted)
+	}
+
+	q.entries = append(q.entries, &retry{message: message, attempts: attempts, err: err, due: due})
+	if key := orderingKey(&message); key != "" {
+		q.held[key]++
+	}
+	return evicted
+}
+
+func (q *retryQueue) remove(entry *retry) {
+	for i, e := range q.entries {
+		if e == entry {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			break
+		}
+	}
+
+	if key := orderingKey(&entry.message); key != "" {
+		if q.held[key]--; q.held[key] == 0 {
+			delete(q.held, key)
+		}
+	}
+}
+
+// hold queues the messages with the same key as any of the queued ones, so they are
+// written after them. It returns the remaining messages along with the ones evicted
+// from the full queue.
+func (q *retryQueue) hold(
+	messages []kafka.Message,
+	now time.Time,
+) ([]kafka.Message, []*retry) {
+	if len(q.held) == 0 {
+		return messages, nil
+	}
+
+	var (
+		kept    = messages[:0]
+		evicted []*retry
+	)
+	for _, message := range messages {
+		if key := orderingKey(&message); key != "" && q.held[key] > 0 {
+			if entry := q.push(message, 0, nil, now); entry != nil {
+				evicted = append(evicted, entry)
+			}
+			continue
+		}
+		kept = append(kept, message)
+	}
+	return kept, evicted
+}
+
+// due returns up to n queued messages due at the given time. The message is not
+// returned if any older message with the same key is not due yet.
+func (q *retryQueue) due(now time.Time, n int) []*retry {
+	var (
+		entries []*retry
+		blocked = make(map[string]bool)
+	)
+
+	for _, entry := range q.entries {
+		if len(entries) == n {
+			break
+		}
+
+		key := orderingKey(&entry.message)
+		if key != "" && blocked[key] {
+			continue
+		}
+
+		if entry.due.After(now) {
+			if key != "" {
+				blocked[key] = true
+			}
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// next returns the time when the earliest queued message is due, or false if the
+// queue is empty.
+func (q *retryQueue) next() (time.Time, bool) {
+	if len(q.entries) == 0 {
+		return time.Time{}, false
+	}
+
+	next := q.entries[0].due
+	for _, entry := range q.entries[1:] {
+		if entry.due.Before(next) {
+			next = entry.due
+		}
+	}
+	return next, true
+}
+
+// drain removes and returns all queued messages in the order they were queued.
+func (q *retryQueue) drain() []*retry {
+	entries := q.entries
+	q.entries = nil
+	clear(q.held)
+	return entries
+}
@@ -0,0 +1,161 @@
+package exporter_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/pkg/exporter"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+var _ = Describe("RetryPolicy", func() {
+	var policy exporter.RetryPolicy
+
+	BeforeEach(func() {
+		policy = exporter.RetryPolicy{
+			MaxRetries:     10,
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     time.Second,
+		}
+	})
+
+	When("computing the backoff", func() {
+		It("should apply the jitter to the initial backoff on the first retry", func() {
+			for i := 0; i < 100; i++ {
+				Expect(policy.Backoff(1)).To(And(
+					BeNumerically(">=", 50*time.Millisecond),
+					BeNumerically("<=", 100*time.Millisecond),
+				))
+			}
+		})
+
+		It("should double the backoff with each retry", func() {
+			Expect(policy.Backoff(3)).To(And(
+				BeNumerically(">=", 200*time.Millisecond),
+				BeNumerically("<=", 400*time.Millisecond),
+			))
+		})
+
+		It("should cap the backoff at the max backoff", func() {
+			for _, retry := range []int{5, 10, 100} {
+				Expect(policy.Backoff(retry)).To(And(
+					BeNumerically(">=", 500*time.Millisecond),
+					BeNumerically("<=", time.Second),
+				))
+			}
+		})
+	})
+})
+
+var _ = Describe("RetryQueue", func() {
+	var (
+		queue *exporter.RetryQueue
+		now   time.Time
+	)
+
+	message := func(key, value string) kafka.Message {
+		message := kafka.Message{Topic: "k8s-events", Value: []byte(value)}
+		if key != "" {
+			message.Key = []byte(key)
+		}
+		return message
+	}
+
+	BeforeEach(func() {
+		queue = exporter.NewRetryQueue(4)
+		now = time.Now()
+	})
+
+	When("holding the new messages", func() {
+		It("should hold only the messages with the key of the queued ones", func() {
+			queue.Push(message("a", "a-1"), now.Add(time.Second))
+
+			kept, evicted := queue.Hold([]kafka.Message{
+				message("a", "a-2"),
+				message("b", "b-1"),
+				message("", "none"),
+			}, now)
+			Expect(kept).To(Equal([]string{"b-1", "none"}))
+			Expect(evicted).To(BeEmpty())
+			Expect(queue.Drain()).To(Equal([]string{"a-1", "a-2"}))
+		})
+
+		It("should not hold the messages without the key", func() {
+			queue.Push(message("", "none-1"), now.Add(time.Second))
+
+			kept, _ := queue.Hold([]kafka.Message{message("", "none-2")}, now)
+			Expect(kept).To(Equal([]string{"none-2"}))
+		})
+
+		It("should keep the order of the messages with the same key", func() {
+			queue.Push(message("a", "a-1"), now.Add(time.Second))
+			queue.Hold([]kafka.Message{message("a", "a-2")}, now)
+			queue.Hold([]kafka.Message{message("a", "a-3")}, now)
+
+			Expect(queue.Take(now, 10)).To(BeEmpty())
+			Expect(queue.Take(now.Add(time.Second), 10)).To(Equal([]string{"a-1", "a-2", "a-3"}))
+		})
+	})
+
+	When("taking the due messages", func() {
+		It("should hold back the later messages with the key of the blocked one", func() {
+			queue.Push(message("a", "a-1"), now.Add(time.Second))
+			queue.Push(message("b", "b-1"), now)
+			queue.Push(message("a", "a-2"), now)
+			queue.Push(message("", "none"), now)
+
+			Expect(queue.Take(now, 10)).To(Equal([]string{"b-1", "none"}))
+			Expect(queue.Take(now.Add(time.Second), 10)).To(Equal([]string{"a-1", "a-2"}))
+		})
+
+		It("should take up to the given number of messages", func() {
+			for _, value := range []string{"0", "1", "2"} {
+				queue.Push(message("", value), now)
+			}
+
+			Expect(queue.Take(now, 2)).To(Equal([]string{"0", "1"}))
+			Expect(queue.Take(now, 2)).To(Equal([]string{"2"}))
+		})
+
+		It("should release the key once its messages are taken", func() {
+			queue.Push(message("a", "a-1"), now)
+			Expect(queue.Take(now, 10)).To(Equal([]string{"a-1"}))
+
+			kept, _ := queue.Hold([]kafka.Message{message("a", "a-2")}, now)
+			Expect(kept).To(Equal([]string{"a-2"}))
+		})
+	})
+
+	When("the queue is full", func() {
+		BeforeEach(func() {
+			queue = exporter.NewRetryQueue(2)
+		})
+
+		It("should evict the oldest messages to make room for the pushed ones", func() {
+			Expect(queue.Push(message("", "0"), now)).To(BeEmpty())
+			Expect(queue.Push(message("", "1"), now)).To(BeEmpty())
+			Expect(queue.Push(message("", "2"), now)).To(Equal("0"))
+			Expect(queue.Push(message("", "3"), now)).To(Equal("1"))
+			Expect(queue.Drain()).To(Equal([]string{"2", "3"}))
+		})
+
+		It("should evict the oldest messages to make room for the held ones", func() {
+			queue.Push(message("a", "a-1"), now.Add(time.Second))
+			queue.Push(message("b", "b-1"), now.Add(time.Second))
+
+			kept, evicted := queue.Hold([]kafka.Message{message("b", "b-2")}, now)
+			Expect(kept).To(BeEmpty())
+			Expect(evicted).To(Equal([]string{"a-1"}))
+			Expect(queue.Drain()).To(Equal([]string{"b-1", "b-2"}))
+		})
+
+		It("should release the key of the evicted messages", func() {
+			queue.Push(message("a", "a-1"), now.Add(time.Second))
+			queue.Push(message("", "0"), now)
+			queue.Push(message("", "1"), now)
+
+			kept, _ := queue.Hold([]kafka.Message{message("a", "a-2")}, now)
+			Expect(kept).To(Equal([]string{"a-2"}))
+		})
+	})
+})
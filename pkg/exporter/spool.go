@@ -0,0 +1,141 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/spool"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// exportSpooled moves the messages from the source buffer to the spool and writes them
+// from the spool to the Kafka topics, acknowledging them once written. Messages which
+// failed to be written are written again according to the retry policy, thus they are
+// delivered at least once.
+func (e *Exporter) exportSpooled(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	subctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		e.spoolMessages(subctx)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := e.writer.Close(); err != nil {
+				return fmt.Errorf("failed to close kafka writer in exporter: %w", err)
+			}
+			return nil
+		case <-e.spool.NotEmpty():
+			if err := e.exportFromSpool(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// exportFromSpool writes the spooled messages in batches until there are no more
+// messages to read. Only the messages which failed to be written are written again
+// after the backoff, before the next ones are read.
+func (e *Exporter) exportFromSpool(ctx context.Context) error {
+	for ctx.Err() == nil {
+		entries := e.failed
+		if len(entries) == 0 {
+			var err error
+			if entries, err = e.spool.Read(e.writer.BatchSize); err != nil {
+				return fmt.Errorf("failed to read messages from spool: %w", err)
+			}
+
+			if len(entries) == 0 {
+				return nil
+			}
+			e.lastRead = entries[len(entries)-1].Offset
+		}
+
+		messages := make([]kafka.Message, len(entries))
+		for i, entry := range entries {
+			messages[i] = *entry.Message
+		}
+
+		errs, err := e.export(ctx, messages)
+		if err != nil {
+			return err
+		}
+
+		var failed []spool.Entry
+		if errs.Count() > 0 {
+			e.failures++
+			for i, werr := range errs {
+				switch {
+				case werr == nil:
+				case e.failures <= e.retry.MaxRetries:
+					failed = append(failed, entries[i])
+				default:
+					e.dropExhausted(&messages[i], e.failures, werr)
+				}
+			}
+		}
+		e.failed = failed
+
+		if len(failed) == 0 {
+			e.failures = 0
+			if err = e.spool.Ack(e.lastRead); err != nil {
+				return fmt.Errorf("failed to acknowledge spooled messages: %w", err)
+			}
+			continue
+		}
+
+		// The acknowledgement covers all preceding messages, thus only the ones written
+		// ahead of the first failed message are acknowledged.
+		if offset := failed[0].Offset; offset > 0 {
+			if err = e.spool.Ack(offset - 1); err != nil {
+				return fmt.Errorf("failed to acknowledge spooled messages: %w", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(e.retry.Backoff(e.failures)):
+		}
+	}
+	return nil
+}
+
+// spoolMessages appends the messages from the source buffer to the spool. Once the context
+// is done, the messages left in the buffer are spooled, so they are written after restart.
+func (e *Exporter) spoolMessages(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			e.appendToSpool(e.source.Drain())
+			return
+		case <-e.source.NotEmpty():
+			e.appendToSpool(e.source.Drain())
+		}
+	}
+}
+
+func (e *Exporter) appendToSpool(messages []*kafka.Message) {
+	if gap := e.reportLoss(); gap != nil {
+		messages = append([]*kafka.Message{gap}, messages...)
+	}
+
+	if len(messages) == 0 {
+		return
+	}
+
+	if err := e.spool.Append(messages...); err != nil {
+		e.logger.Error("failed to append messages to spool, dropping messages",
+			zap.Int("dropped", len(messages)),
+			zap.Error(err),
+		)
+	}
+}
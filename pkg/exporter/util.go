@@ -4,19 +4,16 @@ import (
 	"errors"
 	"github.com/raczu/kube2kafka/pkg/processor"
 	"github.com/segmentio/kafka-go"
+	"time"
 )
 
-// tryReadUpTo tries to read n messages from the buffer. If there are fewer than n messages
-// in the buffer, it returns all available messages. To ensure that some messages are read,
-// the caller should at first check if there are any messages in the buffer.
+// tryReadUpTo tries to read n messages from the buffer at once. If there are fewer than
+// n messages in the buffer, it returns all of them.
 func tryReadUpTo(buffer *processor.KafkaMessageBuffer, n int) []kafka.Message {
-	var messages []kafka.Message
-	for i := 0; i < n; i++ {
-		message, ok := buffer.Read()
-		if !ok {
-			break
-		}
-		messages = append(messages, *message)
+	values := buffer.ReadN(n)
+	messages := make([]kafka.Message, len(values))
+	for i, message := range values {
+		messages[i] = *message
 	}
 	return messages
 }
@@ -31,3 +28,39 @@ func isFatalError(err error) bool {
 		errors.Is(err, kafka.SASLAuthenticationFailed) ||
 		errors.Is(err, kafka.TopicAuthorizationFailed)
 }
+
+// isRoutedTopicError checks if the provided error is caused by the topic, other than the
+// default one, that does not exist. Such an error concerns only messages routed to that
+// topic, thus the exporter is still able to send the remaining messages.
+func isRoutedTopicError(err error, topic, defaultTopic string) bool {
+	return topic != defaultTopic && errors.Is(err, kafka.UnknownTopicOrPartition)
+}
+
+// groupByTopic groups the indexes of the messages by their topic, preserving the order
+// of the messages within each topic.
+func groupByTopic(messages []kafka.Message) [][]int {
+	var groups [][]int
+	seen := make(map[string]int)
+	for i := range messages {
+		group, ok := seen[messages[i].Topic]
+		if !ok {
+			group = len(groups)
+			seen[messages[i].Topic] = group
+			groups = append(groups, nil)
+		}
+		groups[group] = append(groups[group], i)
+	}
+	return groups
+}
+
+// resetTimer stops the timer, draining its channel if it has already fired but was not
+// received from, and resets it to fire after the duration.
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
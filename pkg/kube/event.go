@@ -11,6 +11,16 @@ import (
 type EnhancedEvent struct {
 	corev1.Event `json:",inline"`
 	ClusterName  string `json:"clusterName"`
+	// Severity is the severity assigned to the event by the severity mapping,
+	// which allows to tell apart events more precisely than their type does.
+	Severity string `json:"severity,omitempty"`
+}
+
+// DeepCopy returns the deep copy of the event.
+func (ev *EnhancedEvent) DeepCopy() *EnhancedEvent {
+	out := *ev
+	ev.Event.DeepCopyInto(&out.Event)
+	return &out
 }
 
 func (ev *EnhancedEvent) FirstOccurrence() time.Time {
@@ -21,6 +31,20 @@ func (ev *EnhancedEvent) FirstOccurrence() time.Time {
 	return timestamp
 }
 
+// LastOccurrence returns the timestamp of the most recent occurrence of the event.
+// If the event has no such timestamp, the first occurrence timestamp is returned.
+func (ev *EnhancedEvent) LastOccurrence() time.Time {
+	timestamp := ev.LastTimestamp.Time
+	if ev.Series != nil && !ev.Series.LastObservedTime.IsZero() {
+		timestamp = ev.Series.LastObservedTime.Time
+	}
+
+	if timestamp.IsZero() {
+		timestamp = ev.FirstOccurrence()
+	}
+	return timestamp
+}
+
 func (ev *EnhancedEvent) GetRFC3339Timestamp() string {
 	return ev.FirstOccurrence().Format(time.RFC3339)
 }
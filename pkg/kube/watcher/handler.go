@@ -35,7 +35,13 @@ func (eh *EventHandler) WriteToBuffer(event *corev1.Event) {
 		zap.String("reason", event.Reason),
 		zap.String("regarding", event.InvolvedObject.Name),
 	)
-	eh.output.Write(ev)
+	if !eh.output.Write(ev) {
+		eh.logger.Warn(
+			"event dropped as the buffer is full",
+			zap.String("namespace", event.Namespace),
+			zap.String("name", event.Name),
+		)
+	}
 }
 
 func (eh *EventHandler) OnAdd(obj interface{}, isInInitialList bool) {
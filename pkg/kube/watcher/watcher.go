@@ -27,8 +27,14 @@ const (
 
 type EventBuffer = circular.RingBuffer[*kube.EnhancedEvent]
 
-func NewEventBuffer(capacity int) *EventBuffer {
-	return circular.NewRingBuffer[*kube.EnhancedEvent](capacity)
+func NewEventBuffer(capacity int, opts ...circular.Option[*kube.EnhancedEvent]) *EventBuffer {
+	return circular.NewRingBuffer[*kube.EnhancedEvent](capacity, opts...)
+}
+
+// EventSize returns the size of the event serialized as protobuf. It is used to account
+// the events in the byte budget of the EventBuffer.
+func EventSize(event *kube.EnhancedEvent) int {
+	return event.Event.Size() + len(event.ClusterName) + len(event.Severity)
 }
 
 type Option func(*Watcher)
@@ -0,0 +1,140 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hamba/avro/v2"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/registry"
+	"github.com/segmentio/kafka-go"
+	"regexp"
+)
+
+const (
+	avroNamespace  = "io.kube2kafka"
+	avroRecordName = "Event"
+)
+
+var avroNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var type2avro = map[ValueType]string{
+	StringType: "string",
+	IntType:    "long",
+	BoolType:   "boolean",
+	// JSON values have no fixed structure, thus they are stored as JSON text.
+	JSONType: "string",
+}
+
+type avroRecord struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Fields    []avroField `json:"fields"`
+}
+
+type avroField struct {
+	Name    string `json:"name"`
+	Type    any    `json:"type"`
+	Default any    `json:"default"`
+}
+
+// MarshalJSON omits the default value of the nested records, which are always present.
+func (f avroField) MarshalJSON() ([]byte, error) {
+	if _, ok := f.Type.(*avroRecord); ok {
+		return json.Marshal(struct {
+			Name string `json:"name"`
+			Type any    `json:"type"`
+		}{f.Name, f.Type})
+	}
+
+	type plain avroField
+	return json.Marshal(plain(f))
+}
+
+// AvroSchema derives the Avro schema of the payload from its fields. All scalar fields
+// are nullable, as selectors may omit their keys, whereas nested objects are represented
+// by records named after their path in the payload.
+func AvroSchema(fields []Field) (string, error) {
+	record, err := newAvroRecord(avroRecordName, fields)
+	if err != nil {
+		return "", err
+	}
+	record.Namespace = avroNamespace
+
+	schema, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	return string(schema), nil
+}
+
+func newAvroRecord(name string, fields []Field) (*avroRecord, error) {
+	record := &avroRecord{
+		Type:   "record",
+		Name:   name,
+		Fields: make([]avroField, 0, len(fields)),
+	}
+
+	for _, field := range fields {
+		if !avroNameRegex.MatchString(field.Name) {
+			return nil, fmt.Errorf("field name %q is not a valid avro name", field.Name)
+		}
+
+		if field.IsObject() {
+			nested, err := newAvroRecord(name+"_"+field.Name, field.Fields)
+			if err != nil {
+				return nil, err
+			}
+			record.Fields = append(record.Fields, avroField{Name: field.Name, Type: nested})
+			continue
+		}
+
+		record.Fields = append(record.Fields, avroField{
+			Name: field.Name,
+			Type: []string{"null", type2avro[field.Type]},
+		})
+	}
+	return record, nil
+}
+
+// AvroEncoder encodes the payload using the Avro schema derived from the payload fields.
+// The encoded payload is framed in the Confluent wire format with the schema identifier.
+type AvroEncoder struct {
+	fields   []Field
+	schema   avro.Schema
+	schemaID int
+}
+
+// NewAvroEncoder creates a new Avro encoder for the payload fields and the identifier
+// of their schema registered in the schema registry.
+func NewAvroEncoder(fields []Field, schemaID int) (*AvroEncoder, error) {
+	text, err := AvroSchema(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := avro.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse avro schema: %w", err)
+	}
+
+	return &AvroEncoder{
+		fields:   fields,
+		schema:   schema,
+		schemaID: schemaID,
+	}, nil
+}
+
+func (e *AvroEncoder) Encode(event *kube.EnhancedEvent, payload any, message *kafka.Message) error {
+	record, err := toRecord(e.fields, fieldMap(event, payload))
+	if err != nil {
+		return err
+	}
+
+	data, err := avro.Marshal(e.schema, record)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload as avro: %w", err)
+	}
+	message.Value = registry.Frame(e.schemaID, data)
+	return nil
+}
@@ -0,0 +1,169 @@
+package processor_test
+
+import (
+	"encoding/binary"
+	"github.com/hamba/avro/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	"github.com/segmentio/kafka-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Avro", func() {
+	When("deriving fields of the payload", func() {
+		It("should return default fields if no selectors are defined", func() {
+			fields := processor.PayloadFields(nil)
+			Expect(fields).To(ContainElement(processor.Field{
+				Name: "reason",
+				Type: processor.StringType,
+			}))
+		})
+
+		It("should group nested keys into objects", func() {
+			fields := processor.PayloadFields([]processor.Selector{
+				{Key: "reason", Value: "{{ .Reason }}"},
+				{Key: "object.kind", Value: "{{ .InvolvedObject.Kind }}"},
+				{Key: "object.count", Value: "{{ .Count }}", Type: processor.IntType},
+			})
+			Expect(fields).To(Equal([]processor.Field{
+				{Name: "reason", Type: processor.StringType},
+				{Name: "object", Fields: []processor.Field{
+					{Name: "kind", Type: processor.StringType},
+					{Name: "count", Type: processor.IntType},
+				}},
+			}))
+		})
+	})
+
+	When("deriving the avro schema", func() {
+		It("should return an error if field name is not a valid avro name", func() {
+			_, err := processor.AvroSchema([]processor.Field{
+				{Name: "involved-object", Type: processor.StringType},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return a parsable schema", func() {
+			schema, err := processor.AvroSchema([]processor.Field{
+				{Name: "count", Type: processor.IntType},
+				{Name: "object", Fields: []processor.Field{
+					{Name: "kind", Type: processor.StringType},
+				}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = avro.Parse(schema)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	When("encoding the payload", func() {
+		var (
+			event   *kube.EnhancedEvent
+			fields  []processor.Field
+			message *kafka.Message
+		)
+
+		BeforeEach(func() {
+			event = &kube.EnhancedEvent{
+				Event: corev1.Event{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: corev1.NamespaceDefault,
+					},
+					InvolvedObject: corev1.ObjectReference{
+						Kind: "Pod",
+					},
+					Reason: "BackOff",
+				},
+				ClusterName: "dev.kube2kafka.local",
+			}
+			fields = []processor.Field{
+				{Name: "reason", Type: processor.StringType},
+				{Name: "count", Type: processor.IntType},
+				{Name: "labels", Type: processor.JSONType},
+				{Name: "object", Fields: []processor.Field{
+					{Name: "kind", Type: processor.StringType},
+				}},
+			}
+			message = &kafka.Message{}
+		})
+
+		// decode returns the record decoded from the framed value. Values of the nullable
+		// fields are decoded as maps keyed by the name of the union type.
+		decode := func(fields []processor.Field, value []byte) map[string]any {
+			schema, err := processor.AvroSchema(fields)
+			Expect(err).NotTo(HaveOccurred())
+
+			var record map[string]any
+			err = avro.Unmarshal(avro.MustParse(schema), value[5:], &record)
+			Expect(err).NotTo(HaveOccurred())
+			return record
+		}
+
+		It("should frame the encoded payload with the schema id", func() {
+			encoder, err := processor.NewAvroEncoder(fields, 7)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = encoder.Encode(event, map[string]any{"reason": "BackOff"}, message)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(message.Value[0]).To(BeZero())
+			Expect(binary.BigEndian.Uint32(message.Value[1:5])).To(Equal(uint32(7)))
+		})
+
+		It("should encode the payload according to the schema", func() {
+			encoder, err := processor.NewAvroEncoder(fields, 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			payload := map[string]any{
+				"reason": "BackOff",
+				"count":  int64(3),
+				"labels": map[string]any{"app": "nginx"},
+				"object": map[string]any{"kind": "Pod"},
+			}
+			err = encoder.Encode(event, payload, message)
+			Expect(err).NotTo(HaveOccurred())
+
+			record := decode(fields, message.Value)
+			Expect(record).To(HaveKeyWithValue("reason", map[string]any{"string": "BackOff"}))
+			Expect(record).To(HaveKeyWithValue("count", map[string]any{"long": int64(3)}))
+			Expect(record).To(HaveKeyWithValue(
+				"labels",
+				map[string]any{"string": `{"app":"nginx"}`},
+			))
+			Expect(record).To(HaveKeyWithValue(
+				"object",
+				HaveKeyWithValue("kind", map[string]any{"string": "Pod"}),
+			))
+		})
+
+		It("should encode missing fields as null", func() {
+			encoder, err := processor.NewAvroEncoder(fields, 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = encoder.Encode(event, map[string]any{"reason": "BackOff"}, message)
+			Expect(err).NotTo(HaveOccurred())
+
+			record := decode(fields, message.Value)
+			Expect(record).To(HaveKeyWithValue("count", BeNil()))
+			Expect(record).To(HaveKeyWithValue("object", map[string]any{"kind": nil}))
+		})
+
+		It("should encode default fields if payload is the event", func() {
+			fields = processor.PayloadFields(nil)
+			encoder, err := processor.NewAvroEncoder(fields, 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = encoder.Encode(event, event, message)
+			Expect(err).NotTo(HaveOccurred())
+
+			record := decode(fields, message.Value)
+			Expect(record).To(HaveKeyWithValue(
+				"cluster",
+				map[string]any{"string": "dev.kube2kafka.local"},
+			))
+			Expect(record).To(HaveKeyWithValue("kind", map[string]any{"string": "Pod"}))
+		})
+	})
+})
@@ -0,0 +1,149 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	// cloudEventsTypePrefix is prepended to the event reason to create the type
+	// of the CloudEvent, e.g. io.k8s.event.BackOff.
+	cloudEventsTypePrefix = "io.k8s.event."
+	// cloudEventsHeaderPrefix is the prefix of the headers holding the CloudEvent
+	// attributes in the binary content mode of the Kafka protocol binding.
+	cloudEventsHeaderPrefix = "ce_"
+	contentTypeHeader       = "content-type"
+	jsonContentType         = "application/json"
+	cloudEventsContentType  = "application/cloudevents+json"
+)
+
+// CloudEventsMode defines the content mode of the CloudEvents Kafka protocol binding.
+type CloudEventsMode string
+
+const (
+	// StructuredMode encodes the whole CloudEvent, including its attributes,
+	// as a JSON document stored in the message value.
+	StructuredMode CloudEventsMode = "structured"
+	// BinaryMode stores the CloudEvent attributes in the message headers,
+	// whereas the message value holds only the event data.
+	BinaryMode CloudEventsMode = "binary"
+)
+
+// MapCloudEventsModeString maps a mode string to a CloudEventsMode.
+func MapCloudEventsModeString(mode string) (CloudEventsMode, error) {
+	switch m := CloudEventsMode(mode); m {
+	case StructuredMode, BinaryMode:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown cloudevents mode: %s", mode)
+	}
+}
+
+// cloudEvent represents the CloudEvents 1.0 envelope in the structured content mode.
+type cloudEvent struct {
+	SpecVersion     string `json:"specversion"`
+	ID              string `json:"id"`
+	Source          string `json:"source"`
+	Type            string `json:"type"`
+	Subject         string `json:"subject,omitempty"`
+	Time            string `json:"time,omitempty"`
+	DataContentType string `json:"datacontenttype"`
+	Data            any    `json:"data"`
+}
+
+func newCloudEvent(event *kube.EnhancedEvent, payload any) *cloudEvent {
+	source := event.ClusterName
+	if event.Namespace != "" {
+		source = fmt.Sprintf("%s/%s", event.ClusterName, event.Namespace)
+	}
+
+	var subject string
+	if event.InvolvedObject.Kind != "" && event.InvolvedObject.Name != "" {
+		subject = fmt.Sprintf("%s/%s", event.InvolvedObject.Kind, event.InvolvedObject.Name)
+	}
+
+	var timestamp string
+	if occurred := event.FirstOccurrence(); !occurred.IsZero() {
+		timestamp = occurred.Format(time.RFC3339)
+	}
+
+	return &cloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              string(event.UID),
+		Source:          source,
+		Type:            cloudEventsTypePrefix + event.Reason,
+		Subject:         subject,
+		Time:            timestamp,
+		DataContentType: jsonContentType,
+		Data:            payload,
+	}
+}
+
+// headers returns the CloudEvent attributes as the message headers
+// used in the binary content mode.
+func (ce *cloudEvent) headers() []kafka.Header {
+	attributes := []struct {
+		name  string
+		value string
+	}{
+		{"specversion", ce.SpecVersion},
+		{"id", ce.ID},
+		{"source", ce.Source},
+		{"type", ce.Type},
+		{"subject", ce.Subject},
+		{"time", ce.Time},
+	}
+
+	headers := make([]kafka.Header, 0, len(attributes)+1)
+	for _, attr := range attributes {
+		if attr.value == "" {
+			continue
+		}
+		headers = append(headers, kafka.Header{
+			Key:   cloudEventsHeaderPrefix + attr.name,
+			Value: []byte(attr.value),
+		})
+	}
+	return append(headers, kafka.Header{
+		Key:   contentTypeHeader,
+		Value: []byte(ce.DataContentType),
+	})
+}
+
+// CloudEventsEncoder encodes the payload as the data of the CloudEvents 1.0 event,
+// following the Kafka protocol binding in the structured or binary content mode.
+type CloudEventsEncoder struct {
+	Mode CloudEventsMode
+}
+
+func (e *CloudEventsEncoder) Encode(
+	event *kube.EnhancedEvent,
+	payload any,
+	message *kafka.Message,
+) error {
+	ce := newCloudEvent(event, payload)
+	if e.Mode == BinaryMode {
+		value, err := json.Marshal(ce.Data)
+		if err != nil {
+			return err
+		}
+		message.Value = value
+		message.Headers = setHeaders(message.Headers, ce.headers()...)
+		return nil
+	}
+
+	value, err := json.Marshal(ce)
+	if err != nil {
+		return err
+	}
+	message.Value = value
+	message.Headers = setHeaders(message.Headers, kafka.Header{
+		Key:   contentTypeHeader,
+		Value: []byte(cloudEventsContentType),
+	})
+	return nil
+}
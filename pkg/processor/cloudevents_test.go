@@ -0,0 +1,152 @@
+package processor_test
+
+import (
+	"encoding/json"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	"github.com/segmentio/kafka-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+var _ = Describe("CloudEventsEncoder", func() {
+	var (
+		event   *kube.EnhancedEvent
+		payload map[string]any
+		message *kafka.Message
+	)
+
+	BeforeEach(func() {
+		event = &kube.EnhancedEvent{
+			Event: corev1.Event{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: corev1.NamespaceDefault,
+					UID:       "4f0b7b36-3d5a-4f6b-9d1a-3b4f6c9e3c1d",
+				},
+				InvolvedObject: corev1.ObjectReference{
+					Kind: "Pod",
+					Name: "nginx",
+				},
+				Reason:         "BackOff",
+				Type:           "Warning",
+				FirstTimestamp: metav1.NewTime(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)),
+			},
+			ClusterName: "dev.kube2kafka.local",
+		}
+		payload = map[string]any{"reason": "BackOff"}
+		message = &kafka.Message{}
+	})
+
+	When("mapping a mode string", func() {
+		It("should return the corresponding mode", func() {
+			mode, err := processor.MapCloudEventsModeString("binary")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mode).To(Equal(processor.BinaryMode))
+		})
+
+		It("should return an error for unknown mode", func() {
+			_, err := processor.MapCloudEventsModeString("batched")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	When("encoding in structured mode", func() {
+		It("should encode the whole cloud event in the message value", func() {
+			encoder := &processor.CloudEventsEncoder{Mode: processor.StructuredMode}
+			err := encoder.Encode(event, payload, message)
+			Expect(err).NotTo(HaveOccurred())
+
+			var ce map[string]any
+			err = json.Unmarshal(message.Value, &ce)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ce).To(Equal(map[string]any{
+				"specversion":     "1.0",
+				"id":              string(event.UID),
+				"source":          "dev.kube2kafka.local/default",
+				"type":            "io.k8s.event.BackOff",
+				"subject":         "Pod/nginx",
+				"time":            "2024-01-01T12:00:00Z",
+				"datacontenttype": "application/json",
+				"data":            payload,
+			}))
+			Expect(message.Headers).To(ContainElement(kafka.Header{
+				Key:   "content-type",
+				Value: []byte("application/cloudevents+json"),
+			}))
+		})
+
+		It("should replace the configured content type header", func() {
+			message.Headers = []kafka.Header{{Key: "content-type", Value: []byte("text/plain")}}
+			encoder := &processor.CloudEventsEncoder{Mode: processor.StructuredMode}
+			err := encoder.Encode(event, payload, message)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(message.Headers).To(Equal([]kafka.Header{
+				{Key: "content-type", Value: []byte("application/cloudevents+json")},
+			}))
+		})
+
+		It("should use cluster name as source for cluster-scoped events", func() {
+			event.Namespace = ""
+			encoder := &processor.CloudEventsEncoder{Mode: processor.StructuredMode}
+			err := encoder.Encode(event, payload, message)
+			Expect(err).NotTo(HaveOccurred())
+
+			var ce map[string]any
+			err = json.Unmarshal(message.Value, &ce)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ce).To(HaveKeyWithValue("source", event.ClusterName))
+		})
+	})
+
+	When("encoding in binary mode", func() {
+		It("should encode attributes in headers and data in the message value", func() {
+			message.Headers = []kafka.Header{{Key: "cluster", Value: []byte("dev")}}
+			encoder := &processor.CloudEventsEncoder{Mode: processor.BinaryMode}
+			err := encoder.Encode(event, payload, message)
+			Expect(err).NotTo(HaveOccurred())
+
+			var data map[string]any
+			err = json.Unmarshal(message.Value, &data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal(payload))
+
+			Expect(message.Headers).To(Equal([]kafka.Header{
+				{Key: "cluster", Value: []byte("dev")},
+				{Key: "ce_specversion", Value: []byte("1.0")},
+				{Key: "ce_id", Value: []byte(event.UID)},
+				{Key: "ce_source", Value: []byte("dev.kube2kafka.local/default")},
+				{Key: "ce_type", Value: []byte("io.k8s.event.BackOff")},
+				{Key: "ce_subject", Value: []byte("Pod/nginx")},
+				{Key: "ce_time", Value: []byte("2024-01-01T12:00:00Z")},
+				{Key: "content-type", Value: []byte("application/json")},
+			}))
+		})
+
+		It("should replace the configured headers with the same keys", func() {
+			message.Headers = []kafka.Header{
+				{Key: "Content-Type", Value: []byte("text/plain")},
+				{Key: "ce_type", Value: []byte("custom")},
+				{Key: "cluster", Value: []byte("dev")},
+			}
+			encoder := &processor.CloudEventsEncoder{Mode: processor.BinaryMode}
+			err := encoder.Encode(event, payload, message)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(message.Headers).To(Equal([]kafka.Header{
+				{Key: "cluster", Value: []byte("dev")},
+				{Key: "ce_specversion", Value: []byte("1.0")},
+				{Key: "ce_id", Value: []byte(event.UID)},
+				{Key: "ce_source", Value: []byte("dev.kube2kafka.local/default")},
+				{Key: "ce_type", Value: []byte("io.k8s.event.BackOff")},
+				{Key: "ce_subject", Value: []byte("Pod/nginx")},
+				{Key: "ce_time", Value: []byte("2024-01-01T12:00:00Z")},
+				{Key: "content-type", Value: []byte("application/json")},
+			}))
+		})
+	})
+})
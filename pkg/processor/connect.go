@@ -0,0 +1,107 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/segmentio/kafka-go"
+)
+
+// connectSchemaName is the name of the top-level struct of the Kafka Connect schema.
+const connectSchemaName = "io.kube2kafka.Event"
+
+var type2connect = map[ValueType]string{
+	StringType: "string",
+	IntType:    "int64",
+	BoolType:   "boolean",
+	// JSON values have no fixed structure, thus they are stored as JSON text.
+	JSONType: "string",
+}
+
+type connectSchema struct {
+	Type     string          `json:"type"`
+	Name     string          `json:"name,omitempty"`
+	Field    string          `json:"field,omitempty"`
+	Optional bool            `json:"optional"`
+	Fields   []connectSchema `json:"fields,omitempty"`
+}
+
+// ConnectSchema derives the Kafka Connect schema of the payload from its fields. All
+// scalar fields are optional, as selectors may omit their keys, whereas nested objects
+// are represented by required structs.
+func ConnectSchema(fields []Field) (string, error) {
+	schema := newConnectStruct(fields)
+	schema.Name = connectSchemaName
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func newConnectStruct(fields []Field) connectSchema {
+	schema := connectSchema{
+		Type:   "struct",
+		Fields: make([]connectSchema, 0, len(fields)),
+	}
+
+	for _, field := range fields {
+		if field.IsObject() {
+			nested := newConnectStruct(field.Fields)
+			nested.Field = field.Name
+			schema.Fields = append(schema.Fields, nested)
+			continue
+		}
+
+		schema.Fields = append(schema.Fields, connectSchema{
+			Type:     type2connect[field.Type],
+			Field:    field.Name,
+			Optional: true,
+		})
+	}
+	return schema
+}
+
+// ConnectEncoder encodes the payload in the envelope of the Kafka Connect JsonConverter
+// with enabled schemas, i.e. as {"schema": ..., "payload": ...} document.
+type ConnectEncoder struct {
+	fields []Field
+	schema json.RawMessage
+}
+
+// NewConnectEncoder creates a new Kafka Connect encoder for the payload fields.
+func NewConnectEncoder(fields []Field) (*ConnectEncoder, error) {
+	schema, err := ConnectSchema(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive connect schema: %w", err)
+	}
+
+	return &ConnectEncoder{
+		fields: fields,
+		schema: json.RawMessage(schema),
+	}, nil
+}
+
+type connectEnvelope struct {
+	Schema  json.RawMessage `json:"schema"`
+	Payload map[string]any  `json:"payload"`
+}
+
+func (e *ConnectEncoder) Encode(
+	event *kube.EnhancedEvent,
+	payload any,
+	message *kafka.Message,
+) error {
+	record, err := toRecord(e.fields, fieldMap(event, payload))
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(&connectEnvelope{Schema: e.schema, Payload: record})
+	if err != nil {
+		return err
+	}
+	message.Value = value
+	return nil
+}
@@ -0,0 +1,110 @@
+package processor_test
+
+import (
+	"encoding/json"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	"github.com/segmentio/kafka-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("ConnectEncoder", func() {
+	var (
+		event   *kube.EnhancedEvent
+		fields  []processor.Field
+		message *kafka.Message
+	)
+
+	BeforeEach(func() {
+		event = &kube.EnhancedEvent{
+			Event: corev1.Event{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: corev1.NamespaceDefault,
+				},
+				Reason: "BackOff",
+				Count:  3,
+			},
+			ClusterName: "dev.kube2kafka.local",
+		}
+		fields = []processor.Field{
+			{Name: "reason", Type: processor.StringType},
+			{Name: "count", Type: processor.IntType},
+			{Name: "labels", Type: processor.JSONType},
+			{Name: "object", Fields: []processor.Field{
+				{Name: "kind", Type: processor.StringType},
+			}},
+		}
+		message = &kafka.Message{}
+	})
+
+	decode := func(value []byte) map[string]any {
+		var envelope map[string]any
+		err := json.Unmarshal(value, &envelope)
+		Expect(err).NotTo(HaveOccurred())
+		return envelope
+	}
+
+	When("deriving the connect schema", func() {
+		It("should map fields to the connect types", func() {
+			schema, err := processor.ConnectSchema(fields)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(schema).To(MatchJSON(`{
+				"type": "struct",
+				"name": "io.kube2kafka.Event",
+				"optional": false,
+				"fields": [
+					{"type": "string", "field": "reason", "optional": true},
+					{"type": "int64", "field": "count", "optional": true},
+					{"type": "string", "field": "labels", "optional": true},
+					{
+						"type": "struct",
+						"field": "object",
+						"optional": false,
+						"fields": [{"type": "string", "field": "kind", "optional": true}]
+					}
+				]
+			}`))
+		})
+	})
+
+	When("encoding the payload", func() {
+		It("should wrap the payload in the schema envelope", func() {
+			encoder, err := processor.NewConnectEncoder(fields)
+			Expect(err).NotTo(HaveOccurred())
+
+			payload := map[string]any{
+				"reason": "BackOff",
+				"count":  int64(3),
+				"labels": map[string]any{"app": "nginx"},
+			}
+			err = encoder.Encode(event, payload, message)
+			Expect(err).NotTo(HaveOccurred())
+
+			envelope := decode(message.Value)
+			Expect(envelope).To(HaveKeyWithValue("schema", HaveKeyWithValue("type", "struct")))
+			Expect(envelope).To(HaveKeyWithValue("payload", map[string]any{
+				"reason": "BackOff",
+				"count":  float64(3),
+				"labels": `{"app":"nginx"}`,
+				"object": map[string]any{},
+			}))
+		})
+
+		It("should wrap default fields if payload is the event", func() {
+			encoder, err := processor.NewConnectEncoder(processor.PayloadFields(nil))
+			Expect(err).NotTo(HaveOccurred())
+
+			err = encoder.Encode(event, event, message)
+			Expect(err).NotTo(HaveOccurred())
+
+			envelope := decode(message.Value)
+			Expect(envelope).To(HaveKeyWithValue("payload", And(
+				HaveKeyWithValue("cluster", "dev.kube2kafka.local"),
+				HaveKeyWithValue("count", "3"),
+			)))
+		})
+	})
+})
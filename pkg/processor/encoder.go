@@ -0,0 +1,36 @@
+package processor
+
+import (
+	"encoding/json"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/segmentio/kafka-go"
+)
+
+// Encoder serializes the event payload into the kafka.Message. The payload is either
+// the event itself or its customized (and possibly transformed) form. Encoders set the
+// message value and may add headers required by the format.
+type Encoder interface {
+	Encode(event *kube.EnhancedEvent, payload any, message *kafka.Message) error
+}
+
+// boundToSchema checks whether the encoder is bound to the schema derived from the
+// selectors, thus unable to encode the payload of any other shape.
+func boundToSchema(encoder Encoder) bool {
+	switch encoder.(type) {
+	case *AvroEncoder, *ConnectEncoder:
+		return true
+	}
+	return false
+}
+
+// JSONEncoder encodes the payload as a plain JSON document.
+type JSONEncoder struct{}
+
+func (JSONEncoder) Encode(_ *kube.EnhancedEvent, payload any, message *kafka.Message) error {
+	value, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	message.Value = value
+	return nil
+}
@@ -21,11 +21,21 @@ type Filter struct {
 	Type string `yaml:"type"`
 	// Component matches the component of the event source.
 	Component string `yaml:"component"`
+	// Severity matches the severity assigned to the event by the severity mapping.
+	Severity string `yaml:"severity"`
 }
 
 // Validate checks whether all non-empty fields of the filter are valid regular expressions.
 func (f *Filter) Validate() error {
-	patterns := []string{f.Kind, f.Namespace, f.Reason, f.Message, f.Type, f.Component}
+	patterns := []string{
+		f.Kind,
+		f.Namespace,
+		f.Reason,
+		f.Message,
+		f.Type,
+		f.Component,
+		f.Severity,
+	}
 	for _, pattern := range patterns {
 		if pattern == "" {
 			continue
@@ -41,16 +51,19 @@ func (f *Filter) Validate() error {
 // MatchEvent checks if the provided event matches the filter. This means that the event
 // must match all the non-empty fields of the filter.
 func (f *Filter) MatchEvent(event *kube.EnhancedEvent) bool {
-	pairs := map[string]string{
-		f.Kind:      event.InvolvedObject.Kind,
-		f.Namespace: event.Namespace,
-		f.Reason:    event.Reason,
-		f.Message:   event.Message,
-		f.Type:      event.Type,
-		f.Component: event.Source.Component,
+	// The fields may share the same pattern, thus the pairs cannot be keyed by it.
+	pairs := [][2]string{
+		{f.Kind, event.InvolvedObject.Kind},
+		{f.Namespace, event.Namespace},
+		{f.Reason, event.Reason},
+		{f.Message, event.Message},
+		{f.Type, event.Type},
+		{f.Component, event.Source.Component},
+		{f.Severity, event.Severity},
 	}
 
-	for pattern, value := range pairs {
+	for _, pair := range pairs {
+		pattern, value := pair[0], pair[1]
 		if pattern == "" {
 			continue
 		}
@@ -52,6 +52,7 @@ var _ = Describe("Filter", func() {
 				Message:   ".*",
 				Type:      "Normal|Warning",
 				Component: "^kubelet$",
+				Severity:  "critical|high",
 			}
 			err := filter.Validate()
 
@@ -86,6 +87,31 @@ var _ = Describe("Filter", func() {
 			Expect(matched).To(BeTrue())
 		})
 
+		It("should match the severity assigned to the event", func() {
+			filter = processor.Filter{
+				Severity: "^(critical|high)$",
+			}
+			Expect(filter.MatchEvent(event)).To(BeFalse())
+
+			event.Severity = "high"
+			Expect(filter.MatchEvent(event)).To(BeTrue())
+		})
+
+		It("should match all fields sharing the same pattern", func() {
+			filter = processor.Filter{
+				Type:     "^(Warning|high)$",
+				Severity: "^(Warning|high)$",
+			}
+			event.Severity = "high"
+			Expect(filter.MatchEvent(event)).To(BeFalse())
+
+			event.Type = "Warning"
+			Expect(filter.MatchEvent(event)).To(BeTrue())
+
+			event.Severity = ""
+			Expect(filter.MatchEvent(event)).To(BeFalse())
+		})
+
 		Context("and filter fields do not use regular expression syntax", func() {
 			It("should match if event field contain filter value", func() {
 				filter = processor.Filter{
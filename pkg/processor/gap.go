@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/circular"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+const (
+	// GapType is the type of the gap marker, which is also used as the value
+	// of the TypeHeader to tell apart the gap markers from the events.
+	GapType = "gap"
+	// TypeHeader is the header set on the messages not being the events.
+	TypeHeader = "kube2kafka-type"
+)
+
+// Gap is the marker sent in place of the events lost in the buffer due to the overflow,
+// so the consumers can tell the stream of events is incomplete.
+type Gap struct {
+	Type    string `json:"type"`
+	Cluster string `json:"cluster"`
+	// Lost is the number of lost events, which were lost between From and To.
+	Lost int       `json:"lost"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// NewGapMessage creates the message with the gap marker describing the loss. The message
+// is always encoded as JSON, regardless of the output format, and sent to the default topic.
+// Its partition is negative, so with the partition template it is assigned by the fallback
+// balancer rather than piling up on partition 0.
+func NewGapMessage(cluster string, loss circular.Loss) (*kafka.Message, error) {
+	value, err := json.Marshal(Gap{
+		Type:    GapType,
+		Cluster: cluster,
+		Lost:    loss.Count,
+		From:    loss.From.UTC(),
+		To:      loss.To.UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal gap marker: %w", err)
+	}
+
+	return &kafka.Message{
+		Value:     value,
+		Headers:   []kafka.Header{{Key: TypeHeader, Value: []byte(GapType)}},
+		Time:      time.Now(),
+		Partition: -1,
+	}, nil
+}
@@ -0,0 +1,178 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/assert"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/segmentio/kafka-go"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ValidateTemplate checks whether the provided text is a valid template string.
+func ValidateTemplate(text string) error {
+	if _, err := template.New("").Parse(text); err != nil {
+		return fmt.Errorf("template is not valid: %w", err)
+	}
+	return nil
+}
+
+// mustParseTemplate parses the template string, which should be validated beforehand.
+func mustParseTemplate(name, text string) *template.Template {
+	tmpl, err := template.New(name).Parse(text)
+	assert.NoError(err, "template should be pre-validated before use")
+	return tmpl
+}
+
+// renderTemplate executes the template against the event and returns the result.
+func renderTemplate(tmpl *template.Template, event *kube.EnhancedEvent) (string, error) {
+	buff := &bytes.Buffer{}
+	if err := tmpl.Execute(buff, event); err != nil {
+		return "", err
+	}
+	return buff.String(), nil
+}
+
+// KeyFunc returns the key of the kafka.Message created for the event.
+type KeyFunc func(event *kube.EnhancedEvent) ([]byte, error)
+
+// UIDKey uses the event UID as the message key, so the ordering is preserved
+// only between the updates of the same event.
+func UIDKey(event *kube.EnhancedEvent) ([]byte, error) {
+	return []byte(event.UID), nil
+}
+
+// TemplateKey returns a KeyFunc rendering the message key from the template string,
+// e.g. {{ .Namespace }}/{{ .InvolvedObject.Name }}. If the template renders an empty
+// string, the message has no key, which allows the round-robin partitioning.
+func TemplateKey(text string) KeyFunc {
+	tmpl := mustParseTemplate("key", text)
+	return func(event *kube.EnhancedEvent) ([]byte, error) {
+		key, err := renderTemplate(tmpl, event)
+		if err != nil {
+			return nil, err
+		}
+
+		if key == "" {
+			return nil, nil
+		}
+		return []byte(key), nil
+	}
+}
+
+// PartitionFunc returns the partition of the kafka.Message created for the event, which
+// is taken modulo the number of partitions of the topic by the exporter.
+type PartitionFunc func(event *kube.EnhancedEvent) (int, error)
+
+// TemplatePartition returns a PartitionFunc rendering the partition from the template
+// string, e.g. {{ if eq .Type "Warning" }}0{{ else }}1{{ end }}, which must render
+// a non-negative integer.
+func TemplatePartition(text string) PartitionFunc {
+	tmpl := mustParseTemplate("partition", text)
+	return func(event *kube.EnhancedEvent) (int, error) {
+		rendered, err := renderTemplate(tmpl, event)
+		if err != nil {
+			return 0, err
+		}
+
+		partition, err := strconv.Atoi(strings.TrimSpace(rendered))
+		if err != nil || partition < 0 {
+			return 0, fmt.Errorf("partition %q is not a non-negative integer", rendered)
+		}
+		return partition, nil
+	}
+}
+
+// Header is used to add a header to the kafka.Message, so that consumers can route
+// messages without parsing their payload. The value is a template string rendered
+// against the event, e.g. {{ .Reason }} or a constant such as application/json.
+type Header struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+// Validate checks whether the header key is not empty and its value is a valid
+// template string.
+func (h *Header) Validate() error {
+	if h.Key == "" {
+		return fmt.Errorf("key must not be empty")
+	}
+	return ValidateTemplate(h.Value)
+}
+
+// headerTemplate is a header with the pre-parsed value template.
+type headerTemplate struct {
+	key   string
+	value *template.Template
+}
+
+func parseHeaders(headers []Header) []headerTemplate {
+	templates := make([]headerTemplate, 0, len(headers))
+	for _, header := range headers {
+		templates = append(templates, headerTemplate{
+			key:   header.Key,
+			value: mustParseTemplate(header.Key, header.Value),
+		})
+	}
+	return templates
+}
+
+// render renders the header value for the event.
+func (h *headerTemplate) render(event *kube.EnhancedEvent) (kafka.Header, error) {
+	value, err := renderTemplate(h.value, event)
+	if err != nil {
+		return kafka.Header{}, fmt.Errorf("failed to render header %q: %w", h.key, err)
+	}
+	return kafka.Header{Key: h.key, Value: []byte(value)}, nil
+}
+
+// TimestampFunc returns the timestamp of the kafka.Message created for the event.
+// The zero time means that the timestamp is set when the message is sent.
+type TimestampFunc func(event *kube.EnhancedEvent) time.Time
+
+// SendTimestamp leaves the message timestamp to be set when the message is sent.
+func SendTimestamp(_ *kube.EnhancedEvent) time.Time {
+	return time.Time{}
+}
+
+var timestamp2func = map[string]TimestampFunc{
+	"send": SendTimestamp,
+	"first-occurrence": func(event *kube.EnhancedEvent) time.Time {
+		return event.FirstOccurrence()
+	},
+	"last-occurrence": func(event *kube.EnhancedEvent) time.Time {
+		return event.LastOccurrence()
+	},
+}
+
+// MapTimestampString maps a timestamp string to a TimestampFunc.
+func MapTimestampString(timestamp string) (TimestampFunc, error) {
+	if f, ok := timestamp2func[timestamp]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unknown timestamp: %s", timestamp)
+}
+
+// setHeaders appends the headers, replacing the existing ones with the same key, so
+// the headers set by the encoder take precedence over the configured ones. The keys
+// are compared case-insensitively, as consumers often treat them so.
+func setHeaders(headers []kafka.Header, set ...kafka.Header) []kafka.Header {
+	kept := headers[:0]
+	for _, header := range headers {
+		replaced := false
+		for _, s := range set {
+			if strings.EqualFold(header.Key, s.Key) {
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			kept = append(kept, header)
+		}
+	}
+	return append(kept, set...)
+}
@@ -0,0 +1,150 @@
+package processor_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"time"
+)
+
+var _ = Describe("Message", func() {
+	var (
+		event *kube.EnhancedEvent
+	)
+
+	BeforeEach(func() {
+		event = &kube.EnhancedEvent{
+			Event: corev1.Event{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: corev1.NamespaceDefault,
+					UID:       uuid.NewUUID(),
+				},
+				InvolvedObject: corev1.ObjectReference{
+					Kind: "Pod",
+					Name: "nginx",
+				},
+				Reason: "Created",
+				Type:   "Normal",
+			},
+			ClusterName: "dev.kube2kafka.local",
+		}
+	})
+
+	When("validating a template", func() {
+		It("should return an error if template is not valid", func() {
+			err := processor.ValidateTemplate("{{ .Namespace")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not return an error if template is valid", func() {
+			err := processor.ValidateTemplate("{{ .Namespace }}/{{ .InvolvedObject.Name }}")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	When("creating the message key", func() {
+		It("should use event uid by default", func() {
+			key, err := processor.UIDKey(event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(key).To(Equal([]byte(event.UID)))
+		})
+
+		It("should render the key from the template", func() {
+			key, err := processor.TemplateKey("{{ .Namespace }}/{{ .InvolvedObject.Name }}")(event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(key).To(Equal([]byte("default/nginx")))
+		})
+
+		It("should return nil key if template renders an empty string", func() {
+			key, err := processor.TemplateKey("")(event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(key).To(BeNil())
+		})
+
+		It("should return an error if template cannot be rendered", func() {
+			_, err := processor.TemplateKey("{{ .Related.Name }}")(event)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	When("rendering the message partition", func() {
+		It("should render the partition from the template", func() {
+			partition := processor.TemplatePartition(
+				`{{ if eq .Type "Warning" }}0{{ else }}1{{ end }}`,
+			)
+
+			n, err := partition(event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(1))
+
+			event.Type = "Warning"
+			n, err = partition(event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(BeZero())
+		})
+
+		It("should return an error if template does not render a partition", func() {
+			_, err := processor.TemplatePartition("{{ .Namespace }}")(event)
+			Expect(err).To(HaveOccurred())
+
+			_, err = processor.TemplatePartition("-1")(event)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	When("validating a header", func() {
+		It("should return an error if key is empty", func() {
+			header := processor.Header{Value: "{{ .Reason }}"}
+			err := header.Validate()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if value is not a valid template", func() {
+			header := processor.Header{Key: "reason", Value: "{{ .Reason"}
+			err := header.Validate()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not return an error if header has constant value", func() {
+			header := processor.Header{Key: "content-type", Value: "application/json"}
+			err := header.Validate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	When("mapping a timestamp string", func() {
+		BeforeEach(func() {
+			first := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			event.FirstTimestamp = metav1.NewTime(first)
+			event.LastTimestamp = metav1.NewTime(first.Add(time.Hour))
+		})
+
+		It("should return zero time for send timestamp", func() {
+			timestamp, err := processor.MapTimestampString("send")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(timestamp(event).IsZero()).To(BeTrue())
+		})
+
+		It("should return first occurrence of the event", func() {
+			timestamp, err := processor.MapTimestampString("first-occurrence")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(timestamp(event)).To(Equal(event.FirstTimestamp.Time))
+		})
+
+		It("should return last occurrence of the event", func() {
+			timestamp, err := processor.MapTimestampString("last-occurrence")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(timestamp(event)).To(Equal(event.LastTimestamp.Time))
+		})
+
+		It("should return an error for unknown timestamp", func() {
+			timestamp, err := processor.MapTimestampString("unknown")
+			Expect(err).To(HaveOccurred())
+			Expect(timestamp).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,138 @@
+package processor
+
+import (
+	"encoding/json"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/segmentio/kafka-go"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// otlpScopeName is the name of the instrumentation scope of the exported log records.
+const otlpScopeName = "kube2kafka"
+
+// Severity numbers defined by the OpenTelemetry log data model. Events of unknown type
+// are left with the unspecified severity.
+const (
+	otlpSeverityInfo = 9
+	otlpSeverityWarn = 13
+)
+
+var type2severity = map[string]int{
+	"Normal":  otlpSeverityInfo,
+	"Warning": otlpSeverityWarn,
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	// IntValue holds the int64 value, which is encoded as a string in OTLP/JSON.
+	IntValue *string `json:"intValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano,omitempty"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber,omitempty"`
+	SeverityText         string         `json:"severityText,omitempty"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+// otlpLogsData is the OTLP/JSON representation of the ExportLogsServiceRequest.
+type otlpLogsData struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+// attributes is used to build the list of attributes, omitting the empty values.
+type attributes []otlpKeyValue
+
+func (a attributes) string(key, value string) attributes {
+	if value == "" {
+		return a
+	}
+	return append(a, otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}})
+}
+
+func (a attributes) int(key string, value int64) attributes {
+	s := strconv.FormatInt(value, 10)
+	return append(a, otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}})
+}
+
+func unixNano(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// OTLPEncoder encodes the event as the OpenTelemetry log record in the OTLP/JSON format.
+// The log record has a fixed structure built from the event, thus the payload is not
+// taken into account.
+type OTLPEncoder struct{}
+
+func (OTLPEncoder) Encode(event *kube.EnhancedEvent, _ any, message *kafka.Message) error {
+	resource := attributes{}.
+		string("k8s.cluster.name", event.ClusterName).
+		string("k8s.namespace.name", event.Namespace)
+	if kind := event.InvolvedObject.Kind; kind != "" {
+		resource = resource.string(
+			"k8s."+strings.ToLower(kind)+".name",
+			event.InvolvedObject.Name,
+		)
+	}
+
+	attrs := attributes{}.
+		string("k8s.event.uid", string(event.UID)).
+		string("k8s.event.reason", event.Reason).
+		string("k8s.event.component", event.Source.Component).
+		int("k8s.event.count", int64(event.Count))
+
+	body := event.Message
+	record := otlpLogRecord{
+		TimeUnixNano: unixNano(event.LastOccurrence()),
+		// The observed time is the time the event was picked up by kube2kafka, which
+		// must not be taken from the message, as it may carry the event timestamp.
+		ObservedTimeUnixNano: unixNano(time.Now()),
+		SeverityNumber:       type2severity[event.Type],
+		SeverityText:         event.Type,
+		Body:                 otlpAnyValue{StringValue: &body},
+		Attributes:           attrs,
+	}
+
+	scope := otlpScopeLogs{LogRecords: []otlpLogRecord{record}}
+	scope.Scope.Name = otlpScopeName
+
+	logs := otlpResourceLogs{ScopeLogs: []otlpScopeLogs{scope}}
+	logs.Resource.Attributes = resource
+
+	value, err := json.Marshal(&otlpLogsData{ResourceLogs: []otlpResourceLogs{logs}})
+	if err != nil {
+		return err
+	}
+	message.Value = value
+	message.Headers = setHeaders(message.Headers, kafka.Header{
+		Key:   contentTypeHeader,
+		Value: []byte(jsonContentType),
+	})
+	return nil
+}
@@ -0,0 +1,113 @@
+package processor_test
+
+import (
+	"encoding/json"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	"github.com/segmentio/kafka-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strconv"
+	"time"
+)
+
+var _ = Describe("OTLPEncoder", func() {
+	var (
+		event   *kube.EnhancedEvent
+		message *kafka.Message
+	)
+
+	BeforeEach(func() {
+		event = &kube.EnhancedEvent{
+			Event: corev1.Event{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: corev1.NamespaceDefault,
+					UID:       "4f0b7b36-3d5a-4f6b-9d1a-3b4f6c9e3c1d",
+				},
+				InvolvedObject: corev1.ObjectReference{
+					Kind: "Pod",
+					Name: "nginx",
+				},
+				Reason:         "BackOff",
+				Message:        "Back-off restarting failed container",
+				Type:           "Warning",
+				Source:         corev1.EventSource{Component: "kubelet"},
+				Count:          3,
+				FirstTimestamp: metav1.NewTime(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)),
+			},
+			ClusterName: "dev.kube2kafka.local",
+		}
+		message = &kafka.Message{Time: time.Date(2024, 1, 1, 12, 0, 1, 0, time.UTC)}
+	})
+
+	It("should encode the event as OTLP/JSON log record", func() {
+		before := time.Now()
+		err := processor.OTLPEncoder{}.Encode(event, nil, message)
+		Expect(err).NotTo(HaveOccurred())
+
+		var data struct {
+			ResourceLogs []struct {
+				ScopeLogs []struct {
+					LogRecords []map[string]any `json:"logRecords"`
+				} `json:"scopeLogs"`
+			} `json:"resourceLogs"`
+		}
+		err = json.Unmarshal(message.Value, &data)
+		Expect(err).NotTo(HaveOccurred())
+
+		raw := data.ResourceLogs[0].ScopeLogs[0].LogRecords[0]["observedTimeUnixNano"]
+		observed, err := strconv.ParseInt(raw.(string), 10, 64)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Unix(0, observed)).To(BeTemporally("~", before, time.Second))
+
+		Expect(string(message.Value)).To(MatchJSON(`{
+			"resourceLogs": [{
+				"resource": {
+					"attributes": [
+						{
+							"key": "k8s.cluster.name",
+							"value": {"stringValue": "dev.kube2kafka.local"}
+						},
+						{"key": "k8s.namespace.name", "value": {"stringValue": "default"}},
+						{"key": "k8s.pod.name", "value": {"stringValue": "nginx"}}
+					]
+				},
+				"scopeLogs": [{
+					"scope": {"name": "kube2kafka"},
+					"logRecords": [{
+						"timeUnixNano": "1704110400000000000",
+						"observedTimeUnixNano": "` + raw.(string) + `",
+						"severityNumber": 13,
+						"severityText": "Warning",
+						"body": {"stringValue": "Back-off restarting failed container"},
+						"attributes": [
+							{
+								"key": "k8s.event.uid",
+								"value": {"stringValue": "4f0b7b36-3d5a-4f6b-9d1a-3b4f6c9e3c1d"}
+							},
+							{"key": "k8s.event.reason", "value": {"stringValue": "BackOff"}},
+							{"key": "k8s.event.component", "value": {"stringValue": "kubelet"}},
+							{"key": "k8s.event.count", "value": {"intValue": "3"}}
+						]
+					}]
+				}]
+			}]
+		}`))
+	})
+
+	It("should map normal type to info severity", func() {
+		event.Type = "Normal"
+		err := processor.OTLPEncoder{}.Encode(event, nil, message)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(message.Value)).To(ContainSubstring(`"severityNumber":9`))
+	})
+
+	It("should omit object name attribute for events without involved object", func() {
+		event.InvolvedObject = corev1.ObjectReference{}
+		err := processor.OTLPEncoder{}.Encode(event, nil, message)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(message.Value)).NotTo(ContainSubstring("k8s.pod.name"))
+	})
+})
@@ -2,66 +2,333 @@ package processor
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/raczu/kube2kafka/pkg/assert"
 	"github.com/raczu/kube2kafka/pkg/kube"
+	"k8s.io/client-go/util/jsonpath"
+	"strconv"
+	"strings"
+	"sync"
 	"text/template"
 )
 
+// ValueType defines the type to which the selected value is converted before
+// it is stored in the payload.
+type ValueType string
+
+const (
+	StringType ValueType = "string"
+	IntType    ValueType = "int"
+	BoolType   ValueType = "bool"
+	JSONType   ValueType = "json"
+)
+
+// ErrorPolicy defines how the selector behaves when the value cannot be selected.
+type ErrorPolicy string
+
+const (
+	// SkipOnError omits the key of the failed selector in the payload.
+	SkipOnError ErrorPolicy = "skip"
+	// DefaultOnError stores the default value of the selector under its key.
+	DefaultOnError ErrorPolicy = "default"
+	// FailEventOnError fails the customization of the whole event payload.
+	FailEventOnError ErrorPolicy = "fail-event"
+)
+
+// keySeparator is used to split the selector key into the path of nested objects.
+const keySeparator = "."
+
 // Selector is used to select a specific field from the event.
 // The key is the name under which the value is stored in the payload struct,
 // whereas the value is a template string that is used to extract the desired field.
+// Alternatively, the field can be extracted using JSONPath expression evaluated against
+// the JSON form of the event. Keys containing dots (e.g. object.kind) are stored as
+// nested objects.
 type Selector struct {
 	Key   string `yaml:"key"`
 	Value string `yaml:"value"`
+	// JSONPath is the kubectl-like JSONPath expression (e.g. {.involvedObject.name})
+	// used in place of the template string defined in the value.
+	JSONPath string `yaml:"jsonpath"`
+	// Type is the type to which the selected value is converted. If empty,
+	// the value is stored as a string.
+	Type ValueType `yaml:"type"`
+	// Default is the value used in place of the selected one when the selection fails
+	// and the error policy is set to DefaultOnError. It is converted to the selector type.
+	Default string `yaml:"default"`
+	// OnError defines the behavior when the selection fails. If empty, DefaultOnError
+	// is used when the default value is defined, otherwise FailEventOnError is used.
+	OnError ErrorPolicy `yaml:"onError"`
 }
 
-// Validate checks whether the selector is valid. This means that key must not be empty
-// and the value must be a valid template string.
+// Validate checks whether the selector is valid. This means that key must not be empty,
+// exactly one of value and jsonpath must be defined and be a valid template string or
+// JSONPath expression respectively, and the type must be one of the known types.
 func (s *Selector) Validate() error {
 	if s.Key == "" {
 		return fmt.Errorf("key must not be empty")
 	}
-	if s.Value == "" {
-		return fmt.Errorf("value must not be empty")
+	for _, part := range strings.Split(s.Key, keySeparator) {
+		if part == "" {
+			return fmt.Errorf("key must not contain empty path segments")
+		}
 	}
 
-	if _, err := template.New(s.Key).Parse(s.Value); err != nil {
-		return fmt.Errorf("field selector template is not valid: %w", err)
+	switch {
+	case s.Value == "" && s.JSONPath == "":
+		return fmt.Errorf("either value or jsonpath must not be empty")
+	case s.Value != "" && s.JSONPath != "":
+		return fmt.Errorf("value and jsonpath are mutually exclusive")
+	case s.JSONPath != "":
+		if err := jsonpath.New(s.Key).Parse(s.JSONPath); err != nil {
+			return fmt.Errorf("field selector jsonpath is not valid: %w", err)
+		}
+	default:
+		if _, err := template.New(s.Key).Parse(s.Value); err != nil {
+			return fmt.Errorf("field selector template is not valid: %w", err)
+		}
+	}
+
+	switch s.Type {
+	case "", StringType, IntType, BoolType, JSONType:
+	default:
+		return fmt.Errorf("unknown value type: %s", s.Type)
+	}
+
+	switch s.OnError {
+	case "", SkipOnError, DefaultOnError, FailEventOnError:
+	default:
+		return fmt.Errorf("unknown error policy: %s", s.OnError)
+	}
+
+	if s.errorPolicy() == DefaultOnError {
+		if _, err := s.convert(s.Default); err != nil {
+			return fmt.Errorf("default value is not valid %s: %w", s.Type, err)
+		}
 	}
 	return nil
 }
 
-type FieldSelectionFallback func(event *kube.EnhancedEvent, err error) map[string]string
+// errorPolicy returns the error policy of the selector, taking into account
+// whether the default value is defined when the policy is not set explicitly.
+func (s *Selector) errorPolicy() ErrorPolicy {
+	if s.OnError != "" {
+		return s.OnError
+	}
+
+	if s.Default != "" {
+		return DefaultOnError
+	}
+	return FailEventOnError
+}
+
+// selectValue executes the selector template or JSONPath expression against the event
+// and converts the result to the selector type.
+func (s *Selector) selectValue(event *eventView) (any, error) {
+	buff := &bytes.Buffer{}
+	if s.JSONPath != "" {
+		jp := jsonpath.New(s.Key)
+		err := jp.Parse(s.JSONPath)
+		assert.NoError(err, "selector should be pre-validated before use")
+
+		document, err := event.Document()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = jp.Execute(buff, document); err != nil {
+			return nil, err
+		}
+	} else {
+		tmpl, err := template.New(s.Key).Parse(s.Value)
+		assert.NoError(err, "selector should be pre-validated before use")
+
+		if err = tmpl.Execute(buff, event.EnhancedEvent); err != nil {
+			return nil, err
+		}
+	}
+
+	value, err := s.convert(buff.String())
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to convert value of %q to %s: %w",
+			s.Key,
+			s.Type,
+			err,
+		)
+	}
+	return value, nil
+}
+
+// convert converts the raw selected value to the type defined in the selector.
+func (s *Selector) convert(raw string) (any, error) {
+	switch s.Type {
+	case IntType:
+		return strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	case BoolType:
+		return strconv.ParseBool(strings.TrimSpace(raw))
+	case JSONType:
+		var value any
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	default:
+		return raw, nil
+	}
+}
+
+// ValidateSelectors checks whether the keys of the selectors do not conflict with each
+// other, e.g. whether the same key is not used twice or a key is not used both as
+// a value and as an object holding nested values.
+func ValidateSelectors(selectors []Selector) error {
+	payload := make(map[string]any)
+	for _, selector := range selectors {
+		// Use empty object as a placeholder to detect keys nested under other keys.
+		if err := setNested(payload, selector.Key, struct{}{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setNested stores the value in the payload under the provided key. Dots in the key
+// are treated as separators of nested objects, which are created if they do not exist.
+func setNested(payload map[string]any, key string, value any) error {
+	parts := strings.Split(key, keySeparator)
+	current := payload
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part]
+		if !ok {
+			nested := make(map[string]any)
+			current[part] = nested
+			current = nested
+			continue
+		}
+
+		nested, ok := next.(map[string]any)
+		if !ok {
+			return fmt.Errorf("key %q conflicts with already selected value", key)
+		}
+		current = nested
+	}
+
+	last := parts[len(parts)-1]
+	if _, ok := current[last]; ok {
+		return fmt.Errorf("key %q conflicts with already selected value", key)
+	}
+	current[last] = value
+	return nil
+}
+
+// eventView wraps the event to lazily build its JSON form, which is needed only
+// by the JSONPath selectors and should be built at most once per event.
+type eventView struct {
+	*kube.EnhancedEvent
+	document any
+	err      error
+	built    bool
+}
+
+// Document returns the JSON form of the event as a generic structure. Numbers are kept
+// as json.Number, so JSONPath prints them as they are rather than in the float form,
+// e.g. 1e+06 in place of 1000000.
+func (ev *eventView) Document() (any, error) {
+	if !ev.built {
+		ev.built = true
+		var data []byte
+		data, ev.err = json.Marshal(ev.EnhancedEvent)
+		if ev.err == nil {
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.UseNumber()
+			ev.err = decoder.Decode(&ev.document)
+		}
+	}
+	return ev.document, ev.err
+}
+
+type FieldSelectionFallback func(event *kube.EnhancedEvent, err error) map[string]any
+
+// SelectorErrorHook is a function called each time a selector fails, regardless of its
+// error policy. It receives the total number of errors caused by the selector so far.
+type SelectorErrorHook func(selector *Selector, err error, total uint64)
 
 // PayloadCustomizer is used to customize the final event payload by selecting specific fields
 // from the event and storing them under a specific key in the payload struct.
 type PayloadCustomizer struct {
 	Selectors []Selector
 	// OnSelectionError is a function that is called when an error occurs during the
-	// selection of a field. As some fields in kube.EnhancedEvent are pointers, it is
-	// possible that the field is nil, which would cause an error during the selection.
-	// This function returns a map of key-value pairs that represents the final event payload.
+	// selection of a field and the selector error policy is FailEventOnError. As some
+	// fields in kube.EnhancedEvent are pointers, it is possible that the field is nil,
+	// which would cause an error during the selection. This function returns a map of
+	// key-value pairs that represents the final event payload, or nil to drop the event.
 	OnSelectionError FieldSelectionFallback
+	// OnSelectorError is an optional function called each time any selector fails.
+	OnSelectorError SelectorErrorHook
+
+	mu     sync.Mutex
+	errors map[string]uint64
 }
 
 // Customize selects the fields from the event based on the selectors and return a map of
-// key-value pairs that represents the final event payload.
-func (pc *PayloadCustomizer) Customize(event *kube.EnhancedEvent) map[string]string {
-	payload := make(map[string]string)
-	for _, selector := range pc.Selectors {
-		tmpl, err := template.New(selector.Key).Parse(selector.Value)
-		assert.NoError(err, "selector should be pre-validated before use")
+// key-value pairs that represents the final event payload. Selected values are converted
+// to the types defined in the selectors. Failed selections are handled according to the
+// error policy of the selector. It returns nil if the event is dropped by OnSelectionError.
+func (pc *PayloadCustomizer) Customize(event *kube.EnhancedEvent) map[string]any {
+	payload := make(map[string]any)
+	view := &eventView{EnhancedEvent: event}
+	for i := range pc.Selectors {
+		selector := &pc.Selectors[i]
+		value, err := selector.selectValue(view)
+		if err != nil {
+			pc.recordError(selector, err)
+
+			switch selector.errorPolicy() {
+			case SkipOnError:
+				continue
+			case DefaultOnError:
+				value, err = selector.convert(selector.Default)
+				assert.NoError(err, "selector default should be pre-validated before use")
+			default:
+				return pc.OnSelectionError(event, err)
+			}
+		}
 
-		buff := &bytes.Buffer{}
-		if err = tmpl.Execute(buff, event); err != nil {
+		if err = setNested(payload, selector.Key, value); err != nil {
 			return pc.OnSelectionError(event, err)
 		}
-		payload[selector.Key] = buff.String()
 	}
 	return payload
 }
 
+// ErrorCounts returns the number of errors caused by each of the selectors so far,
+// keyed by the selector key. Selectors that have never failed are omitted.
+func (pc *PayloadCustomizer) ErrorCounts() map[string]uint64 {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	counts := make(map[string]uint64, len(pc.errors))
+	for key, count := range pc.errors {
+		counts[key] = count
+	}
+	return counts
+}
+
+func (pc *PayloadCustomizer) recordError(selector *Selector, err error) {
+	pc.mu.Lock()
+	if pc.errors == nil {
+		pc.errors = make(map[string]uint64)
+	}
+	pc.errors[selector.Key]++
+	total := pc.errors[selector.Key]
+	pc.mu.Unlock()
+
+	if pc.OnSelectorError != nil {
+		pc.OnSelectorError(selector, err, total)
+	}
+}
+
 // RelevantFieldSelection returns a map of key-value pairs containing the relevant
 // fields from the event.
 func RelevantFieldSelection(event *kube.EnhancedEvent) map[string]string {
@@ -35,6 +35,37 @@ var _ = Describe("Selector", func() {
 			Expect(err).To(HaveOccurred())
 		})
 
+		It("should return an error if both value and jsonpath are defined", func() {
+			selector = processor.Selector{
+				Key:      "key",
+				Value:    "{{ .Field }}",
+				JSONPath: "{.field}",
+			}
+			err := selector.Validate()
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if jsonpath is not a valid expression", func() {
+			selector = processor.Selector{
+				Key:      "key",
+				JSONPath: "{.field",
+			}
+			err := selector.Validate()
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not return an error if jsonpath is defined in place of value", func() {
+			selector = processor.Selector{
+				Key:      "key",
+				JSONPath: "{.involvedObject.name}",
+			}
+			err := selector.Validate()
+
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		It("should return an error if value is not a valid template string", func() {
 			selector = processor.Selector{
 				Key:   "key",
@@ -54,6 +85,128 @@ var _ = Describe("Selector", func() {
 
 			Expect(err).NotTo(HaveOccurred())
 		})
+
+		It("should return an error if key contains empty path segments", func() {
+			selector = processor.Selector{
+				Key:   "object..kind",
+				Value: "{{ .Field }}",
+			}
+			err := selector.Validate()
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if type is unknown", func() {
+			selector = processor.Selector{
+				Key:   "key",
+				Value: "{{ .Field }}",
+				Type:  "float",
+			}
+			err := selector.Validate()
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not return an error if type is one of the known types", func() {
+			types := []processor.ValueType{
+				processor.StringType,
+				processor.IntType,
+				processor.BoolType,
+				processor.JSONType,
+			}
+			for _, t := range types {
+				selector = processor.Selector{
+					Key:   "key",
+					Value: "{{ .Field }}",
+					Type:  t,
+				}
+				err := selector.Validate()
+
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	})
+
+	When("validating a selector with error policy", func() {
+		It("should return an error if policy is unknown", func() {
+			selector = processor.Selector{
+				Key:     "key",
+				Value:   "{{ .Field }}",
+				OnError: "ignore",
+			}
+			err := selector.Validate()
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if default value does not match the type", func() {
+			selector = processor.Selector{
+				Key:     "key",
+				Value:   "{{ .Field }}",
+				Type:    processor.IntType,
+				Default: "none",
+			}
+			err := selector.Validate()
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if default policy lacks default of given type", func() {
+			selector = processor.Selector{
+				Key:     "key",
+				Value:   "{{ .Field }}",
+				Type:    processor.BoolType,
+				OnError: processor.DefaultOnError,
+			}
+			err := selector.Validate()
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not return an error if default value matches the type", func() {
+			selector = processor.Selector{
+				Key:     "key",
+				Value:   "{{ .Field }}",
+				Type:    processor.IntType,
+				Default: "0",
+				OnError: processor.DefaultOnError,
+			}
+			err := selector.Validate()
+
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	When("validating multiple selectors", func() {
+		It("should return an error if the same key is used twice", func() {
+			selectors := []processor.Selector{
+				{Key: "kind", Value: "{{ .InvolvedObject.Kind }}"},
+				{Key: "kind", Value: "{{ .Kind }}"},
+			}
+			err := processor.ValidateSelectors(selectors)
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if key is used both as value and object", func() {
+			selectors := []processor.Selector{
+				{Key: "object", Value: "{{ .InvolvedObject.Name }}"},
+				{Key: "object.kind", Value: "{{ .InvolvedObject.Kind }}"},
+			}
+			err := processor.ValidateSelectors(selectors)
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not return an error if nested keys share the same parent", func() {
+			selectors := []processor.Selector{
+				{Key: "object.name", Value: "{{ .InvolvedObject.Name }}"},
+				{Key: "object.kind", Value: "{{ .InvolvedObject.Kind }}"},
+			}
+			err := processor.ValidateSelectors(selectors)
+
+			Expect(err).NotTo(HaveOccurred())
+		})
 	})
 })
 
@@ -106,13 +259,122 @@ var _ = Describe("PayloadCustomizer", func() {
 				Expect(payload).To(HaveKeyWithValue("cluster", event.ClusterName))
 				Expect(payload).To(HaveKeyWithValue("kind", event.InvolvedObject.Kind))
 			})
+
+			It("should convert selected values to the defined types", func() {
+				event.Count = 3
+				selectors = []processor.Selector{
+					{
+						Key:   "count",
+						Value: "{{ .Count }}",
+						Type:  processor.IntType,
+					},
+					{
+						Key:   "warning",
+						Value: `{{ eq .Type "Warning" }}`,
+						Type:  processor.BoolType,
+					},
+					{
+						Key:   "source",
+						Value: `{"component": "{{ .Source.Component }}"}`,
+						Type:  processor.JSONType,
+					},
+				}
+				customizer = processor.PayloadCustomizer{
+					Selectors: selectors,
+				}
+
+				payload := customizer.Customize(event)
+				Expect(payload).To(HaveKeyWithValue("count", int64(3)))
+				Expect(payload).To(HaveKeyWithValue("warning", false))
+				Expect(payload).To(HaveKeyWithValue(
+					"source",
+					map[string]any{"component": "kubelet"},
+				))
+			})
+
+			It("should select values using jsonpath expressions", func() {
+				event.Count = 3
+				selectors = []processor.Selector{
+					{
+						Key:      "kind",
+						JSONPath: "{.involvedObject.kind}",
+					},
+					{
+						Key:      "count",
+						JSONPath: "{.count}",
+						Type:     processor.IntType,
+					},
+					{
+						Key:      "source",
+						JSONPath: "{.source}",
+						Type:     processor.JSONType,
+					},
+					{
+						Key:   "cluster",
+						Value: "{{ .ClusterName }}",
+					},
+				}
+				customizer = processor.PayloadCustomizer{
+					Selectors: selectors,
+				}
+
+				payload := customizer.Customize(event)
+				Expect(payload).To(HaveKeyWithValue("kind", event.InvolvedObject.Kind))
+				Expect(payload).To(HaveKeyWithValue("count", int64(3)))
+				Expect(payload).To(HaveKeyWithValue(
+					"source",
+					map[string]any{"component": "kubelet"},
+				))
+				Expect(payload).To(HaveKeyWithValue("cluster", event.ClusterName))
+			})
+
+			It("should select large numbers using jsonpath expressions", func() {
+				event.Count = 1000000
+				selectors = []processor.Selector{
+					{
+						Key:      "count",
+						JSONPath: "{.count}",
+						Type:     processor.IntType,
+					},
+				}
+				customizer = processor.PayloadCustomizer{
+					Selectors: selectors,
+				}
+
+				payload := customizer.Customize(event)
+				Expect(payload).To(HaveKeyWithValue("count", int64(1000000)))
+			})
+
+			It("should store values with dotted keys as nested objects", func() {
+				selectors = []processor.Selector{
+					{
+						Key:   "object.kind",
+						Value: "{{ .InvolvedObject.Kind }}",
+					},
+					{
+						Key:   "object.meta.namespace",
+						Value: "{{ .Namespace }}",
+					},
+				}
+				customizer = processor.PayloadCustomizer{
+					Selectors: selectors,
+				}
+
+				payload := customizer.Customize(event)
+				Expect(payload).To(HaveKeyWithValue("object", map[string]any{
+					"kind": event.InvolvedObject.Kind,
+					"meta": map[string]any{
+						"namespace": event.Namespace,
+					},
+				}))
+			})
 		})
 
 		Context("and selectors could not get wanted fields", func() {
 			BeforeEach(func() {
-				fallback := func(event *kube.EnhancedEvent, err error) map[string]string {
+				fallback := func(event *kube.EnhancedEvent, err error) map[string]any {
 					customizationErr = err
-					return map[string]string{"dummy": "foo"}
+					return map[string]any{"dummy": "foo"}
 				}
 				customizer = processor.PayloadCustomizer{
 					OnSelectionError: fallback,
@@ -169,6 +431,107 @@ var _ = Describe("PayloadCustomizer", func() {
 				Expect(customizationErr).NotTo(BeNil())
 				Expect(customizationErr.Error()).To(ContainSubstring("nil pointer evaluating"))
 			})
+
+			It("should skip only the failed key when policy is skip", func() {
+				selectors = []processor.Selector{
+					{
+						Key:   "cluster",
+						Value: "{{ .ClusterName }}",
+					},
+					{
+						Key:     "related",
+						Value:   "{{ .Related.Kind }}",
+						OnError: processor.SkipOnError,
+					},
+				}
+				customizer.Selectors = selectors
+
+				payload := customizer.Customize(event)
+				Expect(customizationErr).To(BeNil())
+				Expect(payload).To(HaveKeyWithValue("cluster", event.ClusterName))
+				Expect(payload).NotTo(HaveKey("related"))
+			})
+
+			It("should store the default value when policy is default", func() {
+				selectors = []processor.Selector{
+					{
+						Key:   "cluster",
+						Value: "{{ .ClusterName }}",
+					},
+					{
+						Key:     "related",
+						Value:   "{{ .Related.Kind }}",
+						Default: "unknown",
+						OnError: processor.DefaultOnError,
+					},
+					{
+						Key:     "count",
+						Value:   "{{ .Related.Name }}",
+						Type:    processor.IntType,
+						Default: "0",
+					},
+				}
+				customizer.Selectors = selectors
+
+				payload := customizer.Customize(event)
+				Expect(customizationErr).To(BeNil())
+				Expect(payload).To(HaveKeyWithValue("cluster", event.ClusterName))
+				Expect(payload).To(HaveKeyWithValue("related", "unknown"))
+				Expect(payload).To(HaveKeyWithValue("count", int64(0)))
+			})
+
+			It("should count errors caused by each of the selectors", func() {
+				var hooked []string
+				customizer.OnSelectorError = func(s *processor.Selector, _ error, _ uint64) {
+					hooked = append(hooked, s.Key)
+				}
+				selectors = []processor.Selector{
+					{
+						Key:     "related",
+						Value:   "{{ .Related.Kind }}",
+						OnError: processor.SkipOnError,
+					},
+					{
+						Key:   "cluster",
+						Value: "{{ .ClusterName }}",
+					},
+				}
+				customizer.Selectors = selectors
+
+				_ = customizer.Customize(event)
+				_ = customizer.Customize(event)
+				Expect(customizer.ErrorCounts()).To(Equal(map[string]uint64{"related": 2}))
+				Expect(hooked).To(Equal([]string{"related", "related"}))
+			})
+
+			It("should use fallback function when jsonpath field does not exist", func() {
+				selectors = []processor.Selector{
+					{
+						Key:      "kind",
+						JSONPath: "{.related.kind}",
+					},
+				}
+				customizer.Selectors = selectors
+
+				_ = customizer.Customize(event)
+				Expect(customizationErr).NotTo(BeNil())
+				Expect(customizationErr.Error()).To(ContainSubstring("is not found"))
+			})
+
+			It("should use fallback function when value cannot be converted", func() {
+				selectors = []processor.Selector{
+					{
+						Key:   "kind",
+						Value: "{{ .InvolvedObject.Kind }}",
+						Type:  processor.IntType,
+					},
+				}
+				customizer.Selectors = selectors
+
+				_ = customizer.Customize(event)
+				Expect(customizationErr).NotTo(BeNil())
+				Expect(customizationErr.Error()).To(ContainSubstring("failed to convert value"))
+			})
 		})
 	})
 })
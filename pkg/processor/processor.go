@@ -2,24 +2,25 @@ package processor
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"github.com/raczu/kube2kafka/pkg/circular"
 	"github.com/raczu/kube2kafka/pkg/kube"
 	"github.com/raczu/kube2kafka/pkg/kube/watcher"
 	log "github.com/raczu/kube2kafka/pkg/logger"
 	"github.com/segmentio/kafka-go"
 	"go.uber.org/zap"
-	"time"
 )
 
 const (
 	DefaultKafkaMessageBufferCap = 128
+	// readBatchSize is the maximum number of events read from the source buffer at once.
+	readBatchSize = 64
 )
 
 type KafkaMessageBuffer = circular.RingBuffer[*kafka.Message]
 
-func NewKafkaMessageBuffer(capacity int) *KafkaMessageBuffer {
-	return circular.NewRingBuffer[*kafka.Message](capacity)
+func NewKafkaMessageBuffer(capacity int, opts ...circular.Option[*kafka.Message]) *KafkaMessageBuffer {
+	return circular.NewRingBuffer[*kafka.Message](capacity, opts...)
 }
 
 type Option func(*Processor)
@@ -27,34 +28,86 @@ type Option func(*Processor)
 type Processor struct {
 	source     *watcher.EventBuffer
 	output     *KafkaMessageBuffer
+	severity   *SeverityMapping
 	filters    []Filter
+	redactor   *Redactor
 	customizer *PayloadCustomizer
+	transform  *Transform
+	key        KeyFunc
+	partition  PartitionFunc
+	headers    []headerTemplate
+	timestamp  TimestampFunc
+	router     *Router
+	encoder    Encoder
+	// maxMessageBytes is the maximum size of the message, messages exceeding it
+	// are handled according to the oversizePolicy.
+	maxMessageBytes int
+	oversizePolicy  OversizePolicy
+	deadLetter      DeadLetterFunc
+	// gapMarkers defines whether the gap markers are sent when the events
+	// are lost in the source buffer.
+	gapMarkers bool
 	logger     *zap.Logger
 }
 
 func New(source *watcher.EventBuffer, opts ...Option) *Processor {
 	p := &Processor{
-		source: source,
-		output: NewKafkaMessageBuffer(DefaultKafkaMessageBufferCap),
-		logger: log.New().Named("processor"),
+		source:          source,
+		output:          NewKafkaMessageBuffer(DefaultKafkaMessageBufferCap),
+		key:             UIDKey,
+		timestamp:       SendTimestamp,
+		encoder:         JSONEncoder{},
+		logger:          log.New().Named("processor"),
+		maxMessageBytes: DefaultMaxMessageBytes,
+		oversizePolicy:  DeadLetterPolicy,
+		gapMarkers:      true,
 	}
 
 	for _, opt := range opts {
 		opt(p)
 	}
 
+	if p.deadLetter == nil {
+		p.deadLetter = p.logDeadLetter
+	}
+
 	if p.customizer != nil {
 		sublogger := p.logger.Named("customizer")
+		schemaBound := boundToSchema(p.encoder)
 		// Wrap the customizer to log errors and fallback to default field selection.
-		fallback := func(event *kube.EnhancedEvent, err error) map[string]string {
+		fallback := func(event *kube.EnhancedEvent, err error) map[string]any {
+			if schemaBound {
+				// The default fields do not match the schema derived from the selectors,
+				// thus they would be encoded as nulls.
+				message := &kafka.Message{Topic: p.route(event), Time: p.timestamp(event)}
+				p.deadLetter(event, message, fmt.Errorf(
+					"failed to select fields of the event payload: %w", err,
+				))
+				return nil
+			}
+
 			sublogger.Warn(
 				"error occurred while selecting fields from the event,"+
 					" falling back to default fields selection",
 				zap.Error(err),
 			)
-			return RelevantFieldSelection(event)
+
+			payload := make(map[string]any)
+			for key, value := range RelevantFieldSelection(event) {
+				payload[key] = value
+			}
+			return payload
 		}
 		p.customizer.OnSelectionError = fallback
+		p.customizer.OnSelectorError = func(selector *Selector, err error, total uint64) {
+			sublogger.Warn(
+				"selector failed to select value from the event",
+				zap.String("selector", selector.Key),
+				zap.String("policy", string(selector.errorPolicy())),
+				zap.Uint64("errors", total),
+				zap.Error(err),
+			)
+		}
 	}
 	return p
 }
@@ -65,52 +118,350 @@ func (p *Processor) GetBuffer() *KafkaMessageBuffer {
 	return p.output
 }
 
+// RedactionCounts returns the number of redactions made by each of the redaction rules
+// so far, or nil if the redaction is not enabled.
+func (p *Processor) RedactionCounts() map[string]uint64 {
+	if p.redactor == nil {
+		return nil
+	}
+	return p.redactor.Counts()
+}
+
+// SelectorErrorCounts returns the number of errors caused by each of the selectors so
+// far, or nil if there are no selectors.
+func (p *Processor) SelectorErrorCounts() map[string]uint64 {
+	if p.customizer == nil {
+		return nil
+	}
+	return p.customizer.ErrorCounts()
+}
+
 func (p *Processor) writeToBuffer(event *kube.EnhancedEvent) {
-	var payload []byte
+	if p.redactor != nil {
+		var redactions int
+		event, redactions = p.redactor.Redact(event)
+		if redactions > 0 {
+			p.logger.Debug("event content redacted",
+				zap.String("namespace", event.Namespace),
+				zap.String("name", event.Name),
+				zap.Int("redactions", redactions),
+			)
+		}
+	}
+
+	message, ok := p.buildMessage(event)
+	if !ok {
+		return
+	}
+
+	if size := MessageSize(message); size > p.maxMessageBytes {
+		message, ok = p.guardSize(event, message, size)
+		if !ok {
+			return
+		}
+	}
+	if !p.output.Write(message) {
+		p.logger.Warn("message dropped as the buffer is full",
+			zap.String("namespace", event.Namespace),
+			zap.String("name", event.Name),
+		)
+	}
+}
+
+// buildMessage creates the kafka.Message from the event. It returns false if the event
+// should be skipped, either on purpose or due to the failure. Events which failed to be
+// encoded are handed over to the dead-letter function.
+func (p *Processor) buildMessage(event *kube.EnhancedEvent) (*kafka.Message, bool) {
+	var payload any = event
 	if p.customizer != nil {
 		customized := p.customizer.Customize(event)
-		payload, _ = json.Marshal(customized)
-	} else {
-		payload, _ = json.Marshal(event)
+		if customized == nil {
+			return nil, false
+		}
+		payload = customized
+	}
+
+	if p.transform != nil {
+		transformed, keep, err := p.transform.Apply(payload)
+		if err != nil {
+			p.logger.Warn(
+				"failed to transform the event payload, skipping event",
+				zap.String("namespace", event.Namespace),
+				zap.String("name", event.Name),
+				zap.Error(err),
+			)
+			return nil, false
+		}
+
+		if !keep {
+			p.logger.Debug("event skipped by transform",
+				zap.String("namespace", event.Namespace),
+				zap.String("name", event.Name),
+				zap.String("reason", event.Reason),
+				zap.String("regarding", event.InvolvedObject.Name),
+			)
+			return nil, false
+		}
+		payload = transformed
+	}
+
+	key, err := p.key(event)
+	if err != nil {
+		p.logger.Warn(
+			"failed to render the message key, falling back to event uid",
+			zap.String("namespace", event.Namespace),
+			zap.String("name", event.Name),
+			zap.Error(err),
+		)
+		key, _ = UIDKey(event)
 	}
 
 	message := &kafka.Message{
-		Key:   []byte(event.UID),
-		Value: payload,
+		Topic:   p.route(event),
+		Key:     key,
+		Headers: p.renderHeaders(event),
+		Time:    p.timestamp(event),
+	}
+
+	if p.partition != nil {
+		message.Partition, err = p.partition(event)
+		if err != nil {
+			p.logger.Warn(
+				"failed to render the message partition, falling back to key hash",
+				zap.String("namespace", event.Namespace),
+				zap.String("name", event.Name),
+				zap.Error(err),
+			)
+			// The negative partition makes the exporter assign the partition by the key.
+			message.Partition = -1
+		}
+	}
+
+	if err = p.encoder.Encode(event, payload, message); err != nil {
+		// The message is handed over without the value, as there is nothing to send.
+		message.Value = nil
+		p.deadLetter(event, message, fmt.Errorf("failed to encode the event payload: %w", err))
+		return nil, false
+	}
+	return message, true
+}
+
+// guardSize applies the oversize policy to the event whose message exceeds the maximum
+// size. If the policy fails to shrink the message, it is handed over to the dead-letter
+// function, so it does not make the whole batch fail in the exporter.
+func (p *Processor) guardSize(
+	event *kube.EnhancedEvent,
+	message *kafka.Message,
+	size int,
+) (*kafka.Message, bool) {
+	var (
+		shrunk  *kafka.Message
+		handled bool
+	)
+	switch p.oversizePolicy {
+	case TruncatePolicy:
+		shrunk, handled = p.truncate(event, size)
+	case StripPolicy:
+		shrunk, handled = p.shrink(stripOptionalFields(event))
+	}
+
+	if handled {
+		// The shrunk event has already been handled by buildMessage.
+		return nil, false
+	}
+
+	if shrunk != nil {
+		p.logger.Warn(
+			"message exceeded the maximum size, event has been shrunk",
+			zap.String("namespace", event.Namespace),
+			zap.String("name", event.Name),
+			zap.String("policy", string(p.oversizePolicy)),
+			zap.Int("size", size),
+			zap.Int("shrunk", MessageSize(shrunk)),
+		)
+		return shrunk, true
+	}
+
+	p.deadLetter(event, message, fmt.Errorf(
+		"message size of %d bytes exceeds the limit of %d bytes", size, p.maxMessageBytes,
+	))
+	return nil, false
+}
+
+// shrink builds the message of the shrunk event. It returns nil if the message still
+// exceeds the maximum size, or true if the event has been skipped by buildMessage.
+func (p *Processor) shrink(shrunk *kube.EnhancedEvent) (*kafka.Message, bool) {
+	message, ok := p.buildMessage(shrunk)
+	if !ok {
+		return nil, true
+	}
+
+	if MessageSize(message) > p.maxMessageBytes {
+		return nil, false
+	}
+	return message, false
+}
+
+// truncate shortens the event message to make its message fit the maximum size. Cutting
+// n bytes of the event message shortens the encoded one by at least n bytes, thus cutting
+// the excess is enough. However, the encoding can expand the event message beyond the
+// excess, e.g. due to the escaping in JSON, in which case the longest fitting prefix is
+// searched for.
+func (p *Processor) truncate(event *kube.EnhancedEvent, size int) (*kafka.Message, bool) {
+	n := len(event.Message) - (size - p.maxMessageBytes) - len(truncationMarker)
+	if n >= 0 {
+		return p.shrink(truncateMessage(event, n))
+	}
+
+	var fitting *kafka.Message
+	lo, hi := 0, len(event.Message)-1
+	for lo <= hi {
+		n = lo + (hi-lo)/2
+		message, handled := p.shrink(truncateMessage(event, n))
+		if handled {
+			return nil, true
+		}
+
+		if message != nil {
+			fitting = message
+			lo = n + 1
+		} else {
+			hi = n - 1
+		}
+	}
+	return fitting, false
+}
+
+// logDeadLetter is the default dead-letter function, which logs and drops the message.
+func (p *Processor) logDeadLetter(event *kube.EnhancedEvent, message *kafka.Message, err error) {
+	p.logger.Error(
+		"failed to process the event, dropping message",
+		zap.String("namespace", event.Namespace),
+		zap.String("name", event.Name),
+		zap.String("reason", event.Reason),
+		zap.String("regarding", event.InvolvedObject.Name),
+		zap.Int("size", MessageSize(message)),
+		zap.Error(err),
+	)
+}
+
+// route returns the destination topic of the event. An empty string means that
+// the event should be sent to the default topic.
+func (p *Processor) route(event *kube.EnhancedEvent) string {
+	if p.router == nil {
+		return ""
+	}
+
+	topic, err := p.router.Route(event)
+	if err != nil {
+		p.logger.Warn(
+			"failed to route the event, falling back to default topic",
+			zap.String("namespace", event.Namespace),
+			zap.String("name", event.Name),
+			zap.Error(err),
+		)
+		return ""
 	}
-	p.output.Write(message)
+	return topic
+}
+
+// renderHeaders renders the headers for the event. Headers that cannot be rendered
+// are omitted, so that a single misbehaving template does not affect the others.
+func (p *Processor) renderHeaders(event *kube.EnhancedEvent) []kafka.Header {
+	if len(p.headers) == 0 {
+		return nil
+	}
+
+	headers := make([]kafka.Header, 0, len(p.headers))
+	for i := range p.headers {
+		header, err := p.headers[i].render(event)
+		if err != nil {
+			p.logger.Warn(
+				"failed to render the message header, omitting it",
+				zap.String("namespace", event.Namespace),
+				zap.String("name", event.Name),
+				zap.Error(err),
+			)
+			continue
+		}
+		headers = append(headers, header)
+	}
+	return headers
 }
 
 // Process reads events from the source buffer, applies the filters, customizes the event
 // payload and writes it to the output buffer as ready to be sent kafka.Message.
 func (p *Processor) Process(ctx context.Context) {
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
-
 	for {
 		select {
 		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			event, ok := p.source.Read()
-			if !ok {
-				continue
+			if p.redactor != nil {
+				p.logger.Info("redactions made by rules", zap.Any("counts", p.redactor.Counts()))
 			}
+			return
+		case <-p.source.NotEmpty():
+			// Drain the source continuously in batches, so the events are not overwritten
+			// in the buffer during the event storms.
+			for ctx.Err() == nil {
+				events := p.source.ReadN(readBatchSize)
+				if len(events) == 0 {
+					break
+				}
+
+				if loss := p.source.TakeLoss(); loss.Count > 0 {
+					p.reportLoss(events[0].ClusterName, loss)
+				}
 
-			if len(p.filters) > 0 && !AnyFilterMatches(p.filters, event) {
-				p.logger.Debug("event filtered out",
-					zap.String("namespace", event.Namespace),
-					zap.String("name", event.Name),
-					zap.String("reason", event.Reason),
-					zap.String("regarding", event.InvolvedObject.Name),
-				)
-				continue
+				for _, event := range events {
+					p.process(event)
+				}
 			}
-			p.writeToBuffer(event)
 		}
 	}
 }
 
+// reportLoss logs the events lost in the source buffer and sends the gap marker
+// describing them, if enabled.
+func (p *Processor) reportLoss(cluster string, loss circular.Loss) {
+	p.logger.Warn("events lost due to the buffer overflow",
+		zap.Int("lost", loss.Count),
+		zap.Time("from", loss.From),
+		zap.Time("to", loss.To),
+	)
+
+	if !p.gapMarkers {
+		return
+	}
+
+	message, err := NewGapMessage(cluster, loss)
+	if err != nil {
+		p.logger.Error("failed to create gap marker", zap.Error(err))
+		return
+	}
+
+	if !p.output.Write(message) {
+		p.logger.Warn("gap marker dropped as the buffer is full", zap.Int("lost", loss.Count))
+	}
+}
+
+func (p *Processor) process(event *kube.EnhancedEvent) {
+	// The severity is assigned before filtering, so the filters can match it.
+	if p.severity != nil {
+		event.Severity = p.severity.Classify(event)
+	}
+
+	if len(p.filters) > 0 && !AnyFilterMatches(p.filters, event) {
+		p.logger.Debug("event filtered out",
+			zap.String("namespace", event.Namespace),
+			zap.String("name", event.Name),
+			zap.String("reason", event.Reason),
+			zap.String("regarding", event.InvolvedObject.Name),
+		)
+		return
+	}
+	p.writeToBuffer(event)
+}
+
 // WithLogger sets the logger to be used by the processor.
 func WithLogger(logger *zap.Logger) Option {
 	return func(p *Processor) {
@@ -118,6 +469,14 @@ func WithLogger(logger *zap.Logger) Option {
 	}
 }
 
+// WithSeverity sets the mapping used to assign the severity to the events. The rules
+// of the mapping should be validated beforehand.
+func WithSeverity(mapping *SeverityMapping) Option {
+	return func(p *Processor) {
+		p.severity = mapping
+	}
+}
+
 // WithFilters sets the filters to be applied to the events before processing.
 func WithFilters(filters []Filter) Option {
 	return func(p *Processor) {
@@ -125,6 +484,32 @@ func WithFilters(filters []Filter) Option {
 	}
 }
 
+// WithRedaction sets the rules used to mask the content of the events before their
+// payload is created. The rules should be validated beforehand.
+func WithRedaction(rules []RedactionRule) Option {
+	return func(p *Processor) {
+		p.redactor = NewRedactor(rules)
+	}
+}
+
+// WithSizeGuard sets the maximum size of the message and the policy applied to the events
+// whose messages exceed it. By default, the maximum size is DefaultMaxMessageBytes and the
+// oversized messages are handed over to the dead-letter function.
+func WithSizeGuard(maxMessageBytes int, policy OversizePolicy) Option {
+	return func(p *Processor) {
+		p.maxMessageBytes = maxMessageBytes
+		p.oversizePolicy = policy
+	}
+}
+
+// WithDeadLetter sets the function receiving the messages that cannot be sent to Kafka.
+// By default, such messages are logged and dropped.
+func WithDeadLetter(fn DeadLetterFunc) Option {
+	return func(p *Processor) {
+		p.deadLetter = fn
+	}
+}
+
 // WithSelectors sets the selectors used to customize the final event payload.
 func WithSelectors(selectors []Selector) Option {
 	return func(p *Processor) {
@@ -132,6 +517,71 @@ func WithSelectors(selectors []Selector) Option {
 	}
 }
 
+// WithTransform sets the jq transform applied to the event payload after the selectors.
+// The jq program is compiled immediately, so it should be validated beforehand.
+func WithTransform(transform *Transform) Option {
+	return func(p *Processor) {
+		transform.compile()
+		p.transform = transform
+	}
+}
+
+// WithKey sets the function used to create the key of the kafka.Message. By default,
+// the event UID is used as the key.
+func WithKey(key KeyFunc) Option {
+	return func(p *Processor) {
+		p.key = key
+	}
+}
+
+// WithPartition sets the function used to assign the partition to the messages. By default,
+// the partition is not set and the messages are assigned to partitions by the exporter.
+func WithPartition(fn PartitionFunc) Option {
+	return func(p *Processor) {
+		p.partition = fn
+	}
+}
+
+// WithHeaders sets the headers added to each kafka.Message. The header values are
+// parsed immediately, so they should be validated beforehand.
+func WithHeaders(headers []Header) Option {
+	return func(p *Processor) {
+		p.headers = parseHeaders(headers)
+	}
+}
+
+// WithTimestamp sets the function used to create the timestamp of the kafka.Message.
+// By default, the timestamp is set when the message is sent.
+func WithTimestamp(timestamp TimestampFunc) Option {
+	return func(p *Processor) {
+		p.timestamp = timestamp
+	}
+}
+
+// WithRoutes sets the routes used to send events to topics other than the default one.
+// The topic templates are parsed immediately, so they should be validated beforehand.
+func WithRoutes(routes []Route) Option {
+	return func(p *Processor) {
+		p.router = NewRouter(routes)
+	}
+}
+
+// WithEncoder sets the encoder used to serialize the event payload into the kafka.Message.
+// By default, the payload is encoded as a plain JSON document.
+func WithEncoder(encoder Encoder) Option {
+	return func(p *Processor) {
+		p.encoder = encoder
+	}
+}
+
+// WithGapMarkers sets whether the gap markers are sent when the events are lost
+// in the source buffer. They are sent by default.
+func WithGapMarkers(enabled bool) Option {
+	return func(p *Processor) {
+		p.gapMarkers = enabled
+	}
+}
+
 // WriteTo sets the buffer where the processor writes processed events as
 // ready to be sent kafka.Message.
 func WriteTo(output *KafkaMessageBuffer) Option {
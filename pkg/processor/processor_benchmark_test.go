@@ -0,0 +1,77 @@
+package processor_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/kube/watcher"
+	log "github.com/raczu/kube2kafka/pkg/logger"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// BenchmarkProcessor measures the throughput of the pipeline from the event buffer, through
+// the processor, to the message buffer drained the same way as by the exporter.
+func BenchmarkProcessor(b *testing.B) {
+	const capacity = 128
+
+	source := watcher.NewEventBuffer(capacity)
+	// The output holds all messages, so none of them is overwritten if the reader lags.
+	output := processor.NewKafkaMessageBuffer(b.N)
+	opts := log.Options{Output: &bytes.Buffer{}}
+	proc := processor.New(
+		source,
+		processor.WriteTo(output),
+		processor.WithLogger(log.New(log.UseOptions(&opts))),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go proc.Process(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for read := 0; read < b.N; {
+			<-output.NotEmpty()
+			for {
+				// Read in batches of the default batch size of the exporter.
+				messages := output.ReadN(16)
+				if len(messages) == 0 {
+					break
+				}
+				read += len(messages)
+			}
+		}
+	}()
+
+	event := &kube.EnhancedEvent{
+		Event: corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: corev1.NamespaceDefault,
+				Name:      "nginx.17c3b0d6d5c0b1a2",
+			},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "nginx"},
+			Reason:         "BackOff",
+			Message:        "Back-off restarting failed container",
+			Type:           "Warning",
+		},
+		ClusterName: "dev.kube2kafka.local",
+	}
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		// Wait for the processor to keep up, so no event is overwritten.
+		for source.Size() == capacity {
+			runtime.Gosched()
+		}
+		source.Write(event)
+	}
+	<-done
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "events/s")
+}
@@ -0,0 +1,129 @@
+package processor
+
+import (
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/registry"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/encoding/protowire"
+	corev1 "k8s.io/api/core/v1"
+	"time"
+)
+
+// Field numbers of the messages defined in api/proto/kube2kafka/v1/event.proto.
+const (
+	eventClusterField        protowire.Number = 1
+	eventUIDField            protowire.Number = 2
+	eventNamespaceField      protowire.Number = 3
+	eventInvolvedObjectField protowire.Number = 4
+	eventReasonField         protowire.Number = 5
+	eventMessageField        protowire.Number = 6
+	eventTypeField           protowire.Number = 7
+	eventComponentField      protowire.Number = 8
+	eventFirstTimestampField protowire.Number = 9
+	eventLastTimestampField  protowire.Number = 10
+	eventCountField          protowire.Number = 11
+)
+
+// ProtobufFraming defines how the protobuf encoded event is framed in the message value.
+type ProtobufFraming string
+
+const (
+	// NoFraming stores the plain protobuf encoded event in the message value.
+	NoFraming ProtobufFraming = "none"
+	// ConfluentFraming prefixes the encoded event with the header of the Confluent
+	// wire format, i.e. the magic byte, schema identifier and message indexes.
+	ConfluentFraming ProtobufFraming = "confluent"
+)
+
+// MapProtobufFramingString maps a framing string to a ProtobufFraming.
+func MapProtobufFramingString(framing string) (ProtobufFraming, error) {
+	switch f := ProtobufFraming(framing); f {
+	case NoFraming, ConfluentFraming:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown protobuf framing: %s", framing)
+	}
+}
+
+// ProtobufEncoder encodes the event as the kube2kafka.v1.Event protobuf message. The
+// message has a fixed schema, thus the payload is not taken into account.
+type ProtobufEncoder struct {
+	Framing ProtobufFraming
+	// SchemaID is the identifier of the schema in the schema registry, used only
+	// by the Confluent framing.
+	SchemaID int
+}
+
+func (e *ProtobufEncoder) Encode(event *kube.EnhancedEvent, _ any, message *kafka.Message) error {
+	value := marshalEventProto(event)
+	if e.Framing == ConfluentFraming {
+		value = registry.FrameProtobuf(e.SchemaID, value)
+	}
+	message.Value = value
+	return nil
+}
+
+func marshalEventProto(event *kube.EnhancedEvent) []byte {
+	var b []byte
+	b = appendString(b, eventClusterField, event.ClusterName)
+	b = appendString(b, eventUIDField, string(event.UID))
+	b = appendString(b, eventNamespaceField, event.Namespace)
+	b = appendMessage(b, eventInvolvedObjectField, marshalObjectReference(&event.InvolvedObject))
+	b = appendString(b, eventReasonField, event.Reason)
+	b = appendString(b, eventMessageField, event.Message)
+	b = appendString(b, eventTypeField, event.Type)
+	b = appendString(b, eventComponentField, event.Source.Component)
+	b = appendMessage(b, eventFirstTimestampField, marshalTimestamp(event.FirstOccurrence()))
+	b = appendMessage(b, eventLastTimestampField, marshalTimestamp(event.LastOccurrence()))
+	if event.Count != 0 {
+		b = protowire.AppendTag(b, eventCountField, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int64(event.Count)))
+	}
+
+	return b
+}
+
+func marshalObjectReference(ref *corev1.ObjectReference) []byte {
+	var b []byte
+	b = appendString(b, 1, ref.Kind)
+	b = appendString(b, 2, ref.Namespace)
+	b = appendString(b, 3, ref.Name)
+	b = appendString(b, 4, string(ref.UID))
+	b = appendString(b, 5, ref.APIVersion)
+	return appendString(b, 6, ref.FieldPath)
+}
+
+// marshalTimestamp encodes the time as the google.protobuf.Timestamp message.
+func marshalTimestamp(t time.Time) []byte {
+	if t.IsZero() {
+		return nil
+	}
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(t.Unix()))
+	if nanos := t.Nanosecond(); nanos != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(nanos))
+	}
+	return b
+}
+
+// appendString appends the string field, omitting it if empty as proto3 does.
+func appendString(b []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// appendMessage appends the embedded message field, omitting it if empty.
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	if len(message) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
@@ -0,0 +1,192 @@
+package processor_test
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/bufbuild/protocompile"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/api"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// compileEventProto compiles the published schema of the event into the descriptor
+// of the kube2kafka.v1.Event message.
+func compileEventProto() protoreflect.MessageDescriptor {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{
+				"kube2kafka/v1/event.proto": api.EventProto,
+			}),
+		}),
+	}
+
+	files, err := compiler.Compile(context.Background(), "kube2kafka/v1/event.proto")
+	Expect(err).NotTo(HaveOccurred())
+	return files[0].Messages().ByName("Event")
+}
+
+// get returns the value of the field of the dynamic message.
+func get(message protoreflect.Message, name string) protoreflect.Value {
+	field := message.Descriptor().Fields().ByName(protoreflect.Name(name))
+	Expect(field).NotTo(BeNil(), "field %s is not defined in the schema", name)
+	return message.Get(field)
+}
+
+// decodeProto decodes the top-level fields of the protobuf message. Values of the
+// repeated fields are collected in the order of their occurrence.
+func decodeProto(b []byte) map[protowire.Number][]any {
+	fields := make(map[protowire.Number][]any)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		Expect(n).To(BeNumerically(">", 0))
+		b = b[n:]
+
+		var value any
+		switch typ {
+		case protowire.VarintType:
+			value, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(b)
+		default:
+			Fail("unexpected wire type")
+		}
+		Expect(n).To(BeNumerically(">", 0))
+		b = b[n:]
+		fields[num] = append(fields[num], value)
+	}
+	return fields
+}
+
+var _ = Describe("ProtobufEncoder", func() {
+	var (
+		event   *kube.EnhancedEvent
+		message *kafka.Message
+	)
+
+	BeforeEach(func() {
+		event = &kube.EnhancedEvent{
+			Event: corev1.Event{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: corev1.NamespaceDefault,
+					UID:       "4f0b7b36-3d5a-4f6b-9d1a-3b4f6c9e3c1d",
+				},
+				InvolvedObject: corev1.ObjectReference{
+					Kind: "Pod",
+					Name: "nginx",
+				},
+				Reason:         "BackOff",
+				Type:           "Warning",
+				Count:          3,
+				FirstTimestamp: metav1.NewTime(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)),
+			},
+			ClusterName: "dev.kube2kafka.local",
+		}
+		message = &kafka.Message{}
+	})
+
+	When("mapping a framing string", func() {
+		It("should return the corresponding framing", func() {
+			framing, err := processor.MapProtobufFramingString("confluent")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(framing).To(Equal(processor.ConfluentFraming))
+		})
+
+		It("should return an error for unknown framing", func() {
+			_, err := processor.MapProtobufFramingString("apicurio")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	It("should encode the event as protobuf message", func() {
+		encoder := &processor.ProtobufEncoder{Framing: processor.NoFraming}
+		err := encoder.Encode(event, nil, message)
+		Expect(err).NotTo(HaveOccurred())
+
+		fields := decodeProto(message.Value)
+		Expect(fields[1]).To(Equal([]any{[]byte("dev.kube2kafka.local")}))
+		Expect(fields[5]).To(Equal([]any{[]byte("BackOff")}))
+		Expect(fields[11]).To(Equal([]any{uint64(3)}))
+		Expect(fields).NotTo(HaveKey(protowire.Number(6)))
+
+		object := decodeProto(fields[4][0].([]byte))
+		Expect(object[1]).To(Equal([]any{[]byte("Pod")}))
+		Expect(object[3]).To(Equal([]any{[]byte("nginx")}))
+
+		timestamp := decodeProto(fields[9][0].([]byte))
+		Expect(timestamp[1]).To(Equal([]any{uint64(event.FirstTimestamp.Unix())}))
+		Expect(fields[10]).To(Equal(fields[9]))
+	})
+
+	It("should encode the event according to the published schema", func() {
+		event.InvolvedObject = corev1.ObjectReference{
+			Kind:       "Pod",
+			Namespace:  corev1.NamespaceDefault,
+			Name:       "nginx",
+			UID:        "b2f1c1e4-8c3a-4d8e-9a6f-2c7d1e5f3a9b",
+			APIVersion: "v1",
+			FieldPath:  "spec.containers{nginx}",
+		}
+		event.Message = "Back-off restarting failed container"
+		event.Source.Component = "kubelet"
+		event.LastTimestamp = metav1.NewTime(time.Date(2024, 1, 1, 12, 5, 0, 500, time.UTC))
+
+		encoder := &processor.ProtobufEncoder{Framing: processor.NoFraming}
+		err := encoder.Encode(event, nil, message)
+		Expect(err).NotTo(HaveOccurred())
+
+		decoded := dynamicpb.NewMessage(compileEventProto())
+		err = proto.Unmarshal(message.Value, decoded)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decoded.GetUnknown()).To(BeEmpty())
+
+		Expect(get(decoded, "cluster").String()).To(Equal("dev.kube2kafka.local"))
+		Expect(get(decoded, "uid").String()).To(Equal(string(event.UID)))
+		Expect(get(decoded, "namespace").String()).To(Equal(corev1.NamespaceDefault))
+		Expect(get(decoded, "reason").String()).To(Equal("BackOff"))
+		Expect(get(decoded, "message").String()).To(Equal(event.Message))
+		Expect(get(decoded, "type").String()).To(Equal("Warning"))
+		Expect(get(decoded, "component").String()).To(Equal("kubelet"))
+		Expect(get(decoded, "count").Int()).To(Equal(int64(3)))
+
+		object := get(decoded, "involved_object").Message()
+		Expect(get(object, "kind").String()).To(Equal("Pod"))
+		Expect(get(object, "namespace").String()).To(Equal(corev1.NamespaceDefault))
+		Expect(get(object, "name").String()).To(Equal("nginx"))
+		Expect(get(object, "uid").String()).To(Equal(string(event.InvolvedObject.UID)))
+		Expect(get(object, "api_version").String()).To(Equal("v1"))
+		Expect(get(object, "field_path").String()).To(Equal("spec.containers{nginx}"))
+
+		first := get(decoded, "first_timestamp").Message()
+		Expect(get(first, "seconds").Int()).To(Equal(event.FirstTimestamp.Unix()))
+		Expect(get(first, "nanos").Int()).To(BeZero())
+
+		last := get(decoded, "last_timestamp").Message()
+		Expect(get(last, "seconds").Int()).To(Equal(event.LastTimestamp.Unix()))
+		Expect(get(last, "nanos").Int()).To(Equal(int64(500)))
+	})
+
+	It("should frame the encoded event in the confluent wire format", func() {
+		encoder := &processor.ProtobufEncoder{Framing: processor.ConfluentFraming, SchemaID: 7}
+		err := encoder.Encode(event, nil, message)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(message.Value[0]).To(BeZero())
+		Expect(binary.BigEndian.Uint32(message.Value[1:5])).To(Equal(uint32(7)))
+		Expect(message.Value[5]).To(BeZero())
+
+		plain := &kafka.Message{}
+		err = (&processor.ProtobufEncoder{}).Encode(event, nil, plain)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message.Value[6:]).To(Equal(plain.Value))
+	})
+})
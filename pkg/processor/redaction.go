@@ -0,0 +1,215 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"regexp"
+	"sync"
+)
+
+// DefaultReplacement is used to mask the matches if the rule defines no replacement.
+const DefaultReplacement = "[REDACTED]"
+
+// hashPrefix is prepended to the hashed values, so they can be told apart from the
+// original content. Only the first hashLength characters of the hex digest are kept.
+const (
+	hashPrefix = "sha256:"
+	hashLength = 16
+)
+
+// RedactionField is the field of the event the redaction rule is applied to.
+type RedactionField string
+
+const (
+	MessageField           RedactionField = "message"
+	AnnotationsField       RedactionField = "annotations"
+	LabelsField            RedactionField = "labels"
+	InvolvedObjectField    RedactionField = "involvedObject.name"
+	SourceHostField        RedactionField = "source.host"
+	ReportingInstanceField RedactionField = "reportingInstance"
+)
+
+var redactionFields = map[RedactionField]func(*kube.EnhancedEvent, func(string) string){
+	MessageField: func(event *kube.EnhancedEvent, redact func(string) string) {
+		event.Message = redact(event.Message)
+	},
+	AnnotationsField: func(event *kube.EnhancedEvent, redact func(string) string) {
+		for key, value := range event.Annotations {
+			event.Annotations[key] = redact(value)
+		}
+	},
+	LabelsField: func(event *kube.EnhancedEvent, redact func(string) string) {
+		for key, value := range event.Labels {
+			event.Labels[key] = redact(value)
+		}
+	},
+	InvolvedObjectField: func(event *kube.EnhancedEvent, redact func(string) string) {
+		event.InvolvedObject.Name = redact(event.InvolvedObject.Name)
+	},
+	SourceHostField: func(event *kube.EnhancedEvent, redact func(string) string) {
+		event.Source.Host = redact(event.Source.Host)
+	},
+	ReportingInstanceField: func(event *kube.EnhancedEvent, redact func(string) string) {
+		event.ReportingInstance = redact(event.ReportingInstance)
+	},
+}
+
+// RedactionRule is used to mask the content of the event fields matching the pattern.
+// Matches are either replaced with the replacement, which can refer to the submatches
+// of the pattern (e.g. ${1}), or with the salted hash of the matched content, which
+// allows to correlate the same values without revealing them.
+type RedactionRule struct {
+	// Name identifies the rule in the redaction counters.
+	Name        string           `yaml:"name"`
+	Fields      []RedactionField `yaml:"fields"`
+	Pattern     string           `yaml:"pattern"`
+	Replacement string           `yaml:"replacement" default:"[REDACTED]"`
+	Hash        bool             `yaml:"hash"`
+	Salt        string           `yaml:"salt"`
+
+	regex *regexp.Regexp
+}
+
+// Validate checks whether the rule is properly defined.
+func (r *RedactionRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	if len(r.Fields) == 0 {
+		return fmt.Errorf("at least one field is required")
+	}
+
+	for _, field := range r.Fields {
+		if _, ok := redactionFields[field]; !ok {
+			return fmt.Errorf("unknown field: %s", field)
+		}
+	}
+
+	if r.Pattern == "" {
+		return fmt.Errorf("pattern is required")
+	}
+
+	if _, err := regexp.Compile(r.Pattern); err != nil {
+		return fmt.Errorf("pattern is not a valid regex: %w", err)
+	}
+
+	if r.Hash && r.Replacement != "" {
+		return fmt.Errorf("replacement and hash are mutually exclusive")
+	}
+
+	if !r.Hash && r.Salt != "" {
+		return fmt.Errorf("salt can be used only with hash")
+	}
+	return nil
+}
+
+// ValidateRedactionRules checks whether the rules are valid and their names are unique.
+func ValidateRedactionRules(rules []RedactionRule) error {
+	names := make(map[string]struct{}, len(rules))
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return fmt.Errorf("rule at index %d has issues: %w", i, err)
+		}
+
+		if _, ok := names[rules[i].Name]; ok {
+			return fmt.Errorf("rule name %q is not unique", rules[i].Name)
+		}
+		names[rules[i].Name] = struct{}{}
+	}
+	return nil
+}
+
+func (r *RedactionRule) hash(value string) string {
+	sum := sha256.Sum256([]byte(r.Salt + value))
+	return hashPrefix + hex.EncodeToString(sum[:])[:hashLength]
+}
+
+// redact masks all matches of the pattern in the value and returns the number
+// of masked matches.
+func (r *RedactionRule) redact(value string) (string, int) {
+	matches := r.regex.FindAllStringSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return value, 0
+	}
+
+	if r.Hash {
+		return r.regex.ReplaceAllStringFunc(value, r.hash), len(matches)
+	}
+	return r.regex.ReplaceAllString(value, r.Replacement), len(matches)
+}
+
+// Redactor applies the redaction rules to the events before their payload is created,
+// so the masked content never reaches the selectors, templates or encoders.
+type Redactor struct {
+	rules []RedactionRule
+
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// NewRedactor creates a new redactor based on the rules, which should be validated beforehand.
+func NewRedactor(rules []RedactionRule) *Redactor {
+	compiled := make([]RedactionRule, len(rules))
+	for i, rule := range rules {
+		rule.regex = regexp.MustCompile(rule.Pattern)
+		if !rule.Hash && rule.Replacement == "" {
+			rule.Replacement = DefaultReplacement
+		}
+		compiled[i] = rule
+	}
+	return &Redactor{rules: compiled}
+}
+
+// Redact returns the copy of the event with the content masked by the rules along with
+// the total number of redactions made. The original event is left untouched.
+func (r *Redactor) Redact(event *kube.EnhancedEvent) (*kube.EnhancedEvent, int) {
+	redacted := event.DeepCopy()
+
+	var total int
+	for i := range r.rules {
+		rule := &r.rules[i]
+
+		var count int
+		redact := func(value string) string {
+			masked, n := rule.redact(value)
+			count += n
+			return masked
+		}
+
+		for _, field := range rule.Fields {
+			redactionFields[field](redacted, redact)
+		}
+
+		if count > 0 {
+			r.record(rule.Name, count)
+			total += count
+		}
+	}
+	return redacted, total
+}
+
+// Counts returns the number of redactions made by each of the rules so far, keyed by
+// the rule name. Rules that have never matched are omitted.
+func (r *Redactor) Counts() map[string]uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]uint64, len(r.counts))
+	for name, count := range r.counts {
+		counts[name] = count
+	}
+	return counts
+}
+
+func (r *Redactor) record(name string, count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.counts == nil {
+		r.counts = make(map[string]uint64)
+	}
+	r.counts[name] += uint64(count)
+}
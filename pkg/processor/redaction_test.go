@@ -0,0 +1,143 @@
+package processor_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Redaction", func() {
+	var (
+		rule processor.RedactionRule
+	)
+
+	BeforeEach(func() {
+		rule = processor.RedactionRule{
+			Name:    "tokens",
+			Fields:  []processor.RedactionField{processor.MessageField},
+			Pattern: `token=\w+`,
+		}
+	})
+
+	When("validating a rule", func() {
+		It("should return an error if name is empty", func() {
+			rule.Name = ""
+			Expect(rule.Validate()).To(HaveOccurred())
+		})
+
+		It("should return an error if no fields are defined", func() {
+			rule.Fields = nil
+			Expect(rule.Validate()).To(HaveOccurred())
+		})
+
+		It("should return an error if field is unknown", func() {
+			rule.Fields = []processor.RedactionField{"spec"}
+			Expect(rule.Validate()).To(HaveOccurred())
+		})
+
+		It("should return an error if pattern is not a valid regex", func() {
+			rule.Pattern = "token=("
+			Expect(rule.Validate()).To(HaveOccurred())
+		})
+
+		It("should return an error if both replacement and hash are defined", func() {
+			rule.Replacement = "token=***"
+			rule.Hash = true
+			Expect(rule.Validate()).To(HaveOccurred())
+		})
+
+		It("should return an error if salt is defined without hash", func() {
+			rule.Salt = "pepper"
+			Expect(rule.Validate()).To(HaveOccurred())
+		})
+
+		It("should not return an error if is properly defined", func() {
+			Expect(rule.Validate()).NotTo(HaveOccurred())
+		})
+
+		It("should return an error if rule names are not unique", func() {
+			err := processor.ValidateRedactionRules([]processor.RedactionRule{rule, rule})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	When("redacting an event", func() {
+		var (
+			event *kube.EnhancedEvent
+		)
+
+		BeforeEach(func() {
+			event = &kube.EnhancedEvent{
+				Event: corev1.Event{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"pull": "token=abc",
+							"node": "node-1",
+						},
+					},
+					Message: "Failed to pull image with token=abc and token=def",
+				},
+				ClusterName: "dev.kube2kafka.local",
+			}
+		})
+
+		It("should replace matches and leave the original event untouched", func() {
+			rule.Fields = append(rule.Fields, processor.AnnotationsField)
+			rule.Replacement = "token=***"
+			redactor := processor.NewRedactor([]processor.RedactionRule{rule})
+
+			redacted, count := redactor.Redact(event)
+			Expect(count).To(Equal(3))
+			Expect(redacted.Message).To(Equal("Failed to pull image with token=*** and token=***"))
+			Expect(redacted.Annotations).To(HaveKeyWithValue("pull", "token=***"))
+			Expect(redacted.Annotations).To(HaveKeyWithValue("node", "node-1"))
+			Expect(redacted.ClusterName).To(Equal(event.ClusterName))
+
+			Expect(event.Message).To(ContainSubstring("token=abc"))
+			Expect(event.Annotations).To(HaveKeyWithValue("pull", "token=abc"))
+		})
+
+		It("should use default replacement if none is defined", func() {
+			redactor := processor.NewRedactor([]processor.RedactionRule{rule})
+			redacted, _ := redactor.Redact(event)
+			Expect(redacted.Message).To(Equal(
+				"Failed to pull image with " + processor.DefaultReplacement +
+					" and " + processor.DefaultReplacement,
+			))
+		})
+
+		It("should replace matches with salted hashes", func() {
+			rule.Hash = true
+			rule.Salt = "pepper"
+			redactor := processor.NewRedactor([]processor.RedactionRule{rule})
+
+			redacted, _ := redactor.Redact(event)
+			Expect(redacted.Message).To(MatchRegexp(
+				`^Failed to pull image with sha256:[0-9a-f]{16} and sha256:[0-9a-f]{16}$`,
+			))
+
+			again, _ := redactor.Redact(event)
+			Expect(again.Message).To(Equal(redacted.Message))
+
+			rule.Salt = "salt"
+			other, _ := processor.NewRedactor([]processor.RedactionRule{rule}).Redact(event)
+			Expect(other.Message).NotTo(Equal(redacted.Message))
+		})
+
+		It("should count redactions made by each rule", func() {
+			hosts := processor.RedactionRule{
+				Name:    "hosts",
+				Fields:  []processor.RedactionField{processor.MessageField},
+				Pattern: `registry\.internal`,
+			}
+			redactor := processor.NewRedactor([]processor.RedactionRule{rule, hosts})
+
+			redactor.Redact(event)
+			redactor.Redact(event)
+			Expect(redactor.Counts()).To(Equal(map[string]uint64{"tokens": 4}))
+		})
+	})
+})
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// maxTopicNameLength is the maximum length of the topic name accepted by Kafka.
+const maxTopicNameLength = 249
+
+var topicNameRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// ValidateTopicName checks whether the name is a legal Kafka topic name.
+func ValidateTopicName(name string) error {
+	if name == "" {
+		return fmt.Errorf("topic name must not be empty")
+	}
+
+	if name == "." || name == ".." {
+		return fmt.Errorf("topic name must not be %q", name)
+	}
+
+	if len(name) > maxTopicNameLength {
+		return fmt.Errorf("topic name must not be longer than %d characters", maxTopicNameLength)
+	}
+
+	if !topicNameRegex.MatchString(name) {
+		return fmt.Errorf(
+			"topic name %q contains characters other than ASCII alphanumerics, '.', '_' and '-'",
+			name,
+		)
+	}
+	return nil
+}
+
+// isTopicTemplate checks whether the topic is a template rather than a plain topic name.
+func isTopicTemplate(topic string) bool {
+	return strings.Contains(topic, "{{")
+}
+
+// Route is used to send events matching the filter to the specific topic instead
+// of the default one.
+type Route struct {
+	// Filter defines which events are routed to the topic.
+	Filter Filter `yaml:"filter"`
+	// Topic is the name of the destination topic or the template string rendered
+	// against the event, e.g. k8s-events-{{ .Namespace }}.
+	Topic string `yaml:"topic"`
+}
+
+// Validate checks whether the filter of the route is valid and the topic is either
+// a legal topic name or a valid template string.
+func (r *Route) Validate() error {
+	if err := r.Filter.Validate(); err != nil {
+		return fmt.Errorf("filter has issues: %w", err)
+	}
+
+	if isTopicTemplate(r.Topic) {
+		return ValidateTemplate(r.Topic)
+	}
+	return ValidateTopicName(r.Topic)
+}
+
+// IsTemplate checks whether the topic of the route is the template string, thus it
+// is known only once the event is routed.
+func (r *Route) IsTemplate() bool {
+	return isTopicTemplate(r.Topic)
+}
+
+// routeTemplate is a route with the pre-parsed topic template.
+type routeTemplate struct {
+	filter Filter
+	topic  *template.Template
+}
+
+// Router selects the destination topic of the event based on the routes. The first
+// route matching the event determines the topic.
+type Router struct {
+	routes []routeTemplate
+}
+
+// NewRouter creates a new router based on the routes, which should be validated beforehand.
+func NewRouter(routes []Route) *Router {
+	templates := make([]routeTemplate, 0, len(routes))
+	for _, route := range routes {
+		templates = append(templates, routeTemplate{
+			filter: route.Filter,
+			topic:  mustParseTemplate("topic", route.Topic),
+		})
+	}
+	return &Router{routes: templates}
+}
+
+// Route returns the destination topic of the event. It returns an empty string if no
+// route matches the event, meaning that the default topic should be used. An error is
+// returned when the matching route renders illegal topic name.
+func (r *Router) Route(event *kube.EnhancedEvent) (string, error) {
+	for i := range r.routes {
+		route := &r.routes[i]
+		if !route.filter.MatchEvent(event) {
+			continue
+		}
+
+		topic, err := renderTemplate(route.topic, event)
+		if err != nil {
+			return "", fmt.Errorf("failed to render topic: %w", err)
+		}
+
+		if err = ValidateTopicName(topic); err != nil {
+			return "", err
+		}
+		return topic, nil
+	}
+	return "", nil
+}
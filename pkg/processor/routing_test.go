@@ -0,0 +1,147 @@
+package processor_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+var _ = Describe("Routing", func() {
+	When("validating a topic name", func() {
+		It("should return an error if name is empty", func() {
+			err := processor.ValidateTopicName("")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if name is a dot or two dots", func() {
+			Expect(processor.ValidateTopicName(".")).To(HaveOccurred())
+			Expect(processor.ValidateTopicName("..")).To(HaveOccurred())
+		})
+
+		It("should return an error if name is too long", func() {
+			err := processor.ValidateTopicName(strings.Repeat("a", 250))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if name contains illegal characters", func() {
+			err := processor.ValidateTopicName("k8s/events")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not return an error if name is legal", func() {
+			err := processor.ValidateTopicName("k8s.events_warning-1")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	When("validating a route", func() {
+		It("should return an error if filter is not valid", func() {
+			route := processor.Route{
+				Filter: processor.Filter{Kind: "[abc"},
+				Topic:  "pods",
+			}
+			err := route.Validate()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if topic name is not legal", func() {
+			route := processor.Route{
+				Filter: processor.Filter{Kind: "Pod"},
+				Topic:  "pod events",
+			}
+			err := route.Validate()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if topic template is not valid", func() {
+			route := processor.Route{
+				Filter: processor.Filter{Kind: "Pod"},
+				Topic:  "k8s-events-{{ .Namespace",
+			}
+			err := route.Validate()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not return an error if topic is a valid template", func() {
+			route := processor.Route{
+				Filter: processor.Filter{Kind: "Pod"},
+				Topic:  "k8s-events-{{ .Namespace }}",
+			}
+			err := route.Validate()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should tell whether topic is a template", func() {
+			route := processor.Route{Topic: "k8s-events-{{ .Namespace }}"}
+			Expect(route.IsTemplate()).To(BeTrue())
+
+			route = processor.Route{Topic: "k8s-events"}
+			Expect(route.IsTemplate()).To(BeFalse())
+		})
+	})
+
+	When("routing an event", func() {
+		var (
+			event  *kube.EnhancedEvent
+			router *processor.Router
+		)
+
+		BeforeEach(func() {
+			event = &kube.EnhancedEvent{
+				Event: corev1.Event{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: corev1.NamespaceDefault,
+					},
+					InvolvedObject: corev1.ObjectReference{
+						Kind: "Pod",
+					},
+					Reason: "Created",
+					Type:   "Warning",
+				},
+			}
+
+			router = processor.NewRouter([]processor.Route{
+				{
+					Filter: processor.Filter{Kind: "^Node$"},
+					Topic:  "nodes",
+				},
+				{
+					Filter: processor.Filter{Type: "Warning"},
+					Topic:  "k8s-warnings-{{ .Namespace }}",
+				},
+				{
+					Filter: processor.Filter{Kind: "Pod"},
+					Topic:  "pods",
+				},
+			})
+		})
+
+		It("should return topic of the first matching route", func() {
+			topic, err := router.Route(event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(topic).To(Equal("k8s-warnings-default"))
+		})
+
+		It("should return empty topic if no route matches", func() {
+			event.InvolvedObject.Kind = "Service"
+			event.Type = "Normal"
+
+			topic, err := router.Route(event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(topic).To(BeEmpty())
+		})
+
+		It("should return an error if rendered topic name is not legal", func() {
+			router = processor.NewRouter([]processor.Route{
+				{Topic: "{{ .Reason }} {{ .Type }}"},
+			})
+
+			_, err := router.Route(event)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
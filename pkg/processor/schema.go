@@ -0,0 +1,122 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"strings"
+)
+
+// relevantFieldNames are the keys of the payload created by RelevantFieldSelection,
+// listed in the order used in the derived schemas.
+var relevantFieldNames = []string{
+	"cluster",
+	"kind",
+	"namespace",
+	"reason",
+	"message",
+	"type",
+	"component",
+	"occurred",
+	"count",
+}
+
+// Field describes a single field of the payload. It is used to derive the schemas
+// of the formats that require them. Field with nested fields represents an object.
+type Field struct {
+	Name   string
+	Type   ValueType
+	Fields []Field
+}
+
+// IsObject checks whether the field holds nested fields.
+func (f *Field) IsObject() bool {
+	return f.Fields != nil
+}
+
+// PayloadFields returns the fields of the payload created by the selectors. If no
+// selectors are provided, the fields of the payload created by RelevantFieldSelection
+// are returned. Selectors should be validated beforehand.
+func PayloadFields(selectors []Selector) []Field {
+	if len(selectors) == 0 {
+		fields := make([]Field, 0, len(relevantFieldNames))
+		for _, name := range relevantFieldNames {
+			fields = append(fields, Field{Name: name, Type: StringType})
+		}
+		return fields
+	}
+
+	var fields []Field
+	for _, selector := range selectors {
+		valueType := selector.Type
+		if valueType == "" {
+			valueType = StringType
+		}
+		fields = addField(fields, strings.Split(selector.Key, keySeparator), valueType)
+	}
+	return fields
+}
+
+func addField(fields []Field, path []string, valueType ValueType) []Field {
+	if len(path) == 1 {
+		return append(fields, Field{Name: path[0], Type: valueType})
+	}
+
+	for i := range fields {
+		if fields[i].Name == path[0] && fields[i].IsObject() {
+			fields[i].Fields = addField(fields[i].Fields, path[1:], valueType)
+			return fields
+		}
+	}
+	return append(fields, Field{
+		Name:   path[0],
+		Fields: addField([]Field{}, path[1:], valueType),
+	})
+}
+
+// fieldMap returns the payload as a map of fields. When the payload is the event itself,
+// i.e. no selectors are used, the relevant fields of the event are returned.
+func fieldMap(event *kube.EnhancedEvent, payload any) map[string]any {
+	if values, ok := payload.(map[string]any); ok {
+		return values
+	}
+
+	values := make(map[string]any)
+	for key, value := range RelevantFieldSelection(event) {
+		values[key] = value
+	}
+	return values
+}
+
+// toRecord prepares the payload values to be encoded as the record of the fields. Values of
+// json fields are replaced with their JSON text and missing nested objects are replaced with
+// empty ones, as the schemas derived from the fields do not allow them to be null.
+func toRecord(fields []Field, values map[string]any) (map[string]any, error) {
+	record := make(map[string]any, len(fields))
+	for _, field := range fields {
+		value, ok := values[field.Name]
+		if field.IsObject() {
+			nested, _ := value.(map[string]any)
+			r, err := toRecord(field.Fields, nested)
+			if err != nil {
+				return nil, err
+			}
+			record[field.Name] = r
+			continue
+		}
+
+		if !ok || value == nil {
+			continue
+		}
+
+		if field.Type == JSONType {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal json field %q: %w", field.Name, err)
+			}
+			value = string(data)
+		}
+		record[field.Name] = value
+	}
+	return record, nil
+}
@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/kube"
+)
+
+// SeverityRule assigns the severity to the events matching the filter.
+type SeverityRule struct {
+	Filter   Filter `yaml:"filter"`
+	Severity string `yaml:"severity"`
+}
+
+// Validate checks whether the filter of the rule is valid and the severity is defined.
+func (r *SeverityRule) Validate() error {
+	if r.Severity == "" {
+		return fmt.Errorf("severity is required")
+	}
+
+	if err := r.Filter.Validate(); err != nil {
+		return fmt.Errorf("filter has issues: %w", err)
+	}
+	return nil
+}
+
+// SeverityMapping is used to classify events by their severity, e.g. critical, high or low,
+// as Kubernetes distinguishes only normal and warning events. The first rule matching the
+// event determines its severity, while events matching none of the rules get the default one.
+type SeverityMapping struct {
+	Default string         `yaml:"default"`
+	Rules   []SeverityRule `yaml:"rules"`
+}
+
+// Validate checks whether all rules of the mapping are valid.
+func (m *SeverityMapping) Validate() error {
+	for i := range m.Rules {
+		if err := m.Rules[i].Validate(); err != nil {
+			return fmt.Errorf("rule at index %d has issues: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Classify returns the severity of the event. Rules should be validated beforehand.
+func (m *SeverityMapping) Classify(event *kube.EnhancedEvent) string {
+	for i := range m.Rules {
+		if m.Rules[i].Filter.MatchEvent(event) {
+			return m.Rules[i].Severity
+		}
+	}
+	return m.Default
+}
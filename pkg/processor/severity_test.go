@@ -0,0 +1,82 @@
+package processor_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("SeverityMapping", func() {
+	var (
+		mapping *processor.SeverityMapping
+		event   *kube.EnhancedEvent
+	)
+
+	BeforeEach(func() {
+		mapping = &processor.SeverityMapping{
+			Default: "low",
+			Rules: []processor.SeverityRule{
+				{
+					Filter:   processor.Filter{Reason: "^(OOMKilling|SystemOOM)$"},
+					Severity: "critical",
+				},
+				{
+					Filter:   processor.Filter{Kind: "^Pod$", Reason: "^FailedMount$"},
+					Severity: "high",
+				},
+				{
+					Filter:   processor.Filter{Message: "(?i)timeout"},
+					Severity: "high",
+				},
+			},
+		}
+		event = &kube.EnhancedEvent{
+			Event: corev1.Event{
+				InvolvedObject: corev1.ObjectReference{
+					Kind: "Pod",
+				},
+				Reason:  "Pulled",
+				Message: "Successfully pulled image",
+				Type:    "Normal",
+			},
+		}
+	})
+
+	When("validating a mapping", func() {
+		It("should return an error if rule has no severity", func() {
+			mapping.Rules[0].Severity = ""
+			Expect(mapping.Validate()).To(HaveOccurred())
+		})
+
+		It("should return an error if rule filter is not valid", func() {
+			mapping.Rules[1].Filter.Reason = "[abc"
+			Expect(mapping.Validate()).To(HaveOccurred())
+		})
+
+		It("should not return an error if all rules are valid", func() {
+			Expect(mapping.Validate()).NotTo(HaveOccurred())
+		})
+	})
+
+	When("classifying an event", func() {
+		It("should return severity of the first matching rule", func() {
+			event.Reason = "FailedMount"
+			event.Message = "Unable to attach volume: timeout expired"
+			Expect(mapping.Classify(event)).To(Equal("high"))
+
+			event.Reason = "OOMKilling"
+			Expect(mapping.Classify(event)).To(Equal("critical"))
+		})
+
+		It("should match the message pattern", func() {
+			event.Message = "Liveness probe failed: Timeout exceeded"
+			Expect(mapping.Classify(event)).To(Equal("high"))
+		})
+
+		It("should return default severity if no rule matches", func() {
+			Expect(mapping.Classify(event)).To(Equal("low"))
+		})
+	})
+})
@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/segmentio/kafka-go"
+	"unicode/utf8"
+)
+
+// DefaultMaxMessageBytes is the default maximum size of the message, which matches
+// the default limits of both the Kafka brokers and the kafka.Writer.
+const DefaultMaxMessageBytes = 1048576
+
+// truncationMarker is appended to the truncated event message.
+const truncationMarker = "... [truncated]"
+
+// messageOverhead is the size of the fixed-length fields of the message, i.e. the CRC,
+// magic byte, attributes, timestamp and the lengths of the key and value.
+const messageOverhead = 4 + 1 + 1 + 8 + 4 + 4
+
+// OversizePolicy defines how the event, whose message exceeds the maximum size, is handled.
+type OversizePolicy string
+
+const (
+	// TruncatePolicy truncates the event message to make the kafka.Message fit.
+	TruncatePolicy OversizePolicy = "truncate"
+	// StripPolicy strips the optional fields of the event, such as annotations, labels,
+	// owner references, managed fields and the related object.
+	StripPolicy OversizePolicy = "strip"
+	// DeadLetterPolicy hands the message over to the dead-letter function as it is.
+	DeadLetterPolicy OversizePolicy = "dead-letter"
+)
+
+// MapOversizePolicyString maps a policy string to an OversizePolicy.
+func MapOversizePolicyString(policy string) (OversizePolicy, error) {
+	switch p := OversizePolicy(policy); p {
+	case TruncatePolicy, StripPolicy, DeadLetterPolicy:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown oversize policy: %s", policy)
+	}
+}
+
+// DeadLetterFunc is called with the messages that cannot be sent to Kafka along with
+// the event they were created from and the reason of the failure. The message has no
+// value if the event failed to be encoded.
+type DeadLetterFunc func(event *kube.EnhancedEvent, message *kafka.Message, err error)
+
+func varintLen(n int) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutVarint(buf[:], int64(n))
+}
+
+// MessageSize returns the size of the message as accounted by the kafka.Writer when
+// checking it against the maximum batch size. It is also used to account the messages
+// in the byte budget of the KafkaMessageBuffer.
+func MessageSize(message *kafka.Message) int {
+	size := messageOverhead + len(message.Key) + len(message.Value)
+	size += varintLen(len(message.Headers))
+	for _, header := range message.Headers {
+		size += varintLen(len(header.Key)) + len(header.Key)
+		size += varintLen(len(header.Value)) + len(header.Value)
+	}
+	return size
+}
+
+// truncateMessage returns the copy of the event with the message shortened to at most
+// n bytes, followed by the truncation marker.
+func truncateMessage(event *kube.EnhancedEvent, n int) *kube.EnhancedEvent {
+	// Cut the message at the rune boundary to keep it valid UTF-8.
+	for n > 0 && !utf8.RuneStart(event.Message[n]) {
+		n--
+	}
+
+	truncated := event.DeepCopy()
+	truncated.Message = event.Message[:n] + truncationMarker
+	return truncated
+}
+
+// stripOptionalFields returns the copy of the event without the fields that are not
+// essential to describe what happened.
+func stripOptionalFields(event *kube.EnhancedEvent) *kube.EnhancedEvent {
+	stripped := event.DeepCopy()
+	stripped.Annotations = nil
+	stripped.Labels = nil
+	stripped.OwnerReferences = nil
+	stripped.Finalizers = nil
+	stripped.ManagedFields = nil
+	stripped.Related = nil
+	return stripped
+}
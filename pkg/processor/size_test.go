@@ -0,0 +1,149 @@
+package processor_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/pkg/kube"
+	"github.com/raczu/kube2kafka/pkg/kube/watcher"
+	"github.com/raczu/kube2kafka/pkg/processor"
+	"github.com/segmentio/kafka-go"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ = Describe("Size guard", func() {
+	When("mapping a policy string", func() {
+		It("should return the corresponding policy", func() {
+			policy, err := processor.MapOversizePolicyString("strip")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(processor.StripPolicy))
+		})
+
+		It("should return an error for unknown policy", func() {
+			_, err := processor.MapOversizePolicyString("compress")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	When("calculating the message size", func() {
+		It("should account key, value and headers", func() {
+			message := &kafka.Message{Key: []byte("key"), Value: []byte("value")}
+			base := processor.MessageSize(message)
+
+			message.Headers = []kafka.Header{{Key: "k", Value: []byte("v")}}
+			// Header key and value are prefixed with their varint-encoded lengths.
+			Expect(processor.MessageSize(message)).To(Equal(base + 4))
+
+			message.Value = []byte("longer value")
+			Expect(processor.MessageSize(message)).To(Equal(base + 4 + 7))
+		})
+	})
+
+	When("processing an oversized event", func() {
+		const maxMessageBytes = 2048
+
+		var (
+			source  *watcher.EventBuffer
+			event   *kube.EnhancedEvent
+			dropped []*kafka.Message
+			mu      sync.Mutex
+			ctx     context.Context
+			cancel  context.CancelFunc
+			wg      sync.WaitGroup
+		)
+
+		deadLetter := func(_ *kube.EnhancedEvent, message *kafka.Message, _ error) {
+			mu.Lock()
+			defer mu.Unlock()
+			dropped = append(dropped, message)
+		}
+
+		run := func(policy processor.OversizePolicy) *processor.Processor {
+			proc := processor.New(
+				source,
+				processor.WithSizeGuard(maxMessageBytes, policy),
+				processor.WithDeadLetter(deadLetter),
+			)
+
+			ctx, cancel = context.WithCancel(context.Background())
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				proc.Process(ctx)
+			}()
+			return proc
+		}
+
+		BeforeEach(func() {
+			source = watcher.NewEventBuffer(1)
+			event = &kube.EnhancedEvent{ClusterName: "dev.kube2kafka.local"}
+			event.Namespace = "default"
+			event.Reason = "BackOff"
+			dropped = nil
+		})
+
+		AfterEach(func() {
+			cancel()
+			wg.Wait()
+		})
+
+		It("should truncate the event message to fit the limit", func() {
+			event.Message = strings.Repeat("a", 4096)
+			source.Write(event)
+			proc := run(processor.TruncatePolicy)
+
+			Eventually(func() int {
+				return proc.GetBuffer().Size()
+			}, 5*time.Second, 100*time.Millisecond).Should(Equal(1))
+
+			msg, _ := proc.GetBuffer().Read()
+			Expect(processor.MessageSize(msg)).To(BeNumerically("<=", maxMessageBytes))
+			Expect(string(msg.Value)).To(ContainSubstring("... [truncated]"))
+		})
+
+		It("should truncate the event message expanded by the encoding to fit the limit", func() {
+			// Each of the characters is escaped as \u003c in JSON.
+			event.Message = strings.Repeat("<", 4096)
+			source.Write(event)
+			proc := run(processor.TruncatePolicy)
+
+			Eventually(func() int {
+				return proc.GetBuffer().Size()
+			}, 5*time.Second, 100*time.Millisecond).Should(Equal(1))
+
+			msg, _ := proc.GetBuffer().Read()
+			Expect(processor.MessageSize(msg)).To(BeNumerically("<=", maxMessageBytes))
+			Expect(string(msg.Value)).To(ContainSubstring(strings.Repeat(`\u003c`, 200)))
+			Expect(string(msg.Value)).To(ContainSubstring("... [truncated]"))
+		})
+
+		It("should strip optional fields of the event to fit the limit", func() {
+			event.Annotations = map[string]string{"large": strings.Repeat("a", 4096)}
+			source.Write(event)
+			proc := run(processor.StripPolicy)
+
+			Eventually(func() int {
+				return proc.GetBuffer().Size()
+			}, 5*time.Second, 100*time.Millisecond).Should(Equal(1))
+
+			msg, _ := proc.GetBuffer().Read()
+			Expect(processor.MessageSize(msg)).To(BeNumerically("<=", maxMessageBytes))
+			Expect(string(msg.Value)).NotTo(ContainSubstring("annotations"))
+		})
+
+		It("should hand the message over to dead-letter function if policy fails", func() {
+			event.Annotations = map[string]string{"large": strings.Repeat("a", 4096)}
+			source.Write(event)
+			proc := run(processor.TruncatePolicy)
+
+			Eventually(func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(dropped)
+			}, 5*time.Second, 100*time.Millisecond).Should(Equal(1))
+			Expect(proc.GetBuffer().Size()).To(BeZero())
+		})
+	})
+})
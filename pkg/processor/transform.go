@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/itchyny/gojq"
+	"github.com/raczu/kube2kafka/pkg/assert"
+	"time"
+)
+
+// transformTimeout limits the execution time of the jq program for a single event,
+// so that programs that never finish do not block the processing of the next events.
+const transformTimeout = 1 * time.Second
+
+// Transform is used to reshape the final event payload using the jq program. It is
+// applied after the selectors, so it receives either the customized payload or the
+// event as it is.
+type Transform struct {
+	// JQ is the jq program applied to the JSON form of the event payload. The first
+	// value produced by the program becomes the new payload. When the program produces
+	// no value or null, the event is skipped.
+	JQ   string `yaml:"jq"`
+	code *gojq.Code
+}
+
+// Validate checks whether the jq program is defined and can be compiled.
+func (t *Transform) Validate() error {
+	if t.JQ == "" {
+		return fmt.Errorf("jq program must not be empty")
+	}
+
+	if _, err := compileJQ(t.JQ); err != nil {
+		return fmt.Errorf("jq program is not valid: %w", err)
+	}
+	return nil
+}
+
+// Apply runs the jq program against the JSON form of the payload. It returns the
+// transformed payload and true if the event should be kept, or false if the program
+// produced no value or null.
+func (t *Transform) Apply(payload any) (any, bool, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	// The jq program accepts only generic JSON values, thus the payload has to be
+	// converted from its typed representation. The numbers are kept as json.Number,
+	// which jq turns into int or *big.Int, so large integers do not lose precision.
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var input any
+	if err = decoder.Decode(&input); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	if t.code == nil {
+		t.compile()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), transformTimeout)
+	defer cancel()
+
+	iter := t.code.RunWithContext(ctx, input)
+	result, ok := iter.Next()
+	if !ok || result == nil {
+		return nil, false, nil
+	}
+
+	if err, ok = result.(error); ok {
+		return nil, false, fmt.Errorf("jq program failed: %w", err)
+	}
+	return result, true, nil
+}
+
+// compile compiles the jq program, which should be validated beforehand.
+func (t *Transform) compile() {
+	code, err := compileJQ(t.JQ)
+	assert.NoError(err, "transform should be pre-validated before use")
+	t.code = code
+}
+
+func compileJQ(program string) (*gojq.Code, error) {
+	query, err := gojq.Parse(program)
+	if err != nil {
+		return nil, err
+	}
+	return gojq.Compile(query)
+}
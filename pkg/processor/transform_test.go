@@ -0,0 +1,100 @@
+package processor_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/pkg/processor"
+)
+
+var _ = Describe("Transform", func() {
+	var (
+		transform *processor.Transform
+	)
+
+	When("validating a transform", func() {
+		It("should return an error if jq program is empty", func() {
+			transform = &processor.Transform{}
+			err := transform.Validate()
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if jq program cannot be parsed", func() {
+			transform = &processor.Transform{JQ: ".foo |"}
+			err := transform.Validate()
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error if jq program cannot be compiled", func() {
+			transform = &processor.Transform{JQ: "undefined_function(.)"}
+			err := transform.Validate()
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not return an error if jq program is valid", func() {
+			transform = &processor.Transform{JQ: "{kind: .kind, count: (.count | tonumber)}"}
+			err := transform.Validate()
+
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	When("applying a transform", func() {
+		var (
+			payload map[string]any
+		)
+
+		BeforeEach(func() {
+			payload = map[string]any{
+				"kind":   "Pod",
+				"reason": "Created",
+				"count":  int64(3),
+			}
+		})
+
+		It("should return the reshaped payload", func() {
+			transform = &processor.Transform{JQ: "{object: .kind, count: (.count + 1)}"}
+
+			result, keep, err := transform.Apply(payload)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keep).To(BeTrue())
+			Expect(result).To(Equal(map[string]any{"object": "Pod", "count": 4}))
+		})
+
+		It("should skip the event if program returns null", func() {
+			transform = &processor.Transform{JQ: `if .kind == "Pod" then null else . end`}
+
+			_, keep, err := transform.Apply(payload)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keep).To(BeFalse())
+		})
+
+		It("should skip the event if program returns no value", func() {
+			transform = &processor.Transform{JQ: `select(.reason != "Created")`}
+
+			_, keep, err := transform.Apply(payload)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keep).To(BeFalse())
+		})
+
+		It("should keep the precision of large integers", func() {
+			payload["uid"] = int64(9007199254740993)
+			transform = &processor.Transform{JQ: "{uid: .uid}"}
+
+			result, keep, err := transform.Apply(payload)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keep).To(BeTrue())
+			Expect(result).To(Equal(map[string]any{"uid": 9007199254740993}))
+		})
+
+		It("should return an error if program fails", func() {
+			transform = &processor.Transform{JQ: `error("boom")`}
+
+			_, keep, err := transform.Apply(payload)
+			Expect(err).To(HaveOccurred())
+			Expect(keep).To(BeFalse())
+		})
+	})
+})
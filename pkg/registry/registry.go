@@ -0,0 +1,180 @@
+// Package registry provides a minimal client of the Confluent Schema Registry along with
+// the framing of messages in the Confluent wire format.
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// magicByte is the first byte of the message in the Confluent wire format.
+	magicByte   = 0
+	contentType = "application/vnd.schemaregistry.v1+json"
+	// DefaultTimeout is the default timeout of requests sent to the registry.
+	DefaultTimeout = 10 * time.Second
+)
+
+// SchemaType is the type of the schema registered in the registry.
+type SchemaType string
+
+const (
+	Avro     SchemaType = "AVRO"
+	Protobuf SchemaType = "PROTOBUF"
+)
+
+type Option func(*Client)
+
+// Client is used to register schemas or look up their identifiers in the registry.
+type Client struct {
+	url      string
+	username string
+	password string
+	http     *http.Client
+}
+
+func NewClient(url string, opts ...Option) *Client {
+	c := &Client{
+		url:  strings.TrimSuffix(url, "/"),
+		http: &http.Client{Timeout: DefaultTimeout},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type schemaRequest struct {
+	Schema     string     `json:"schema"`
+	SchemaType SchemaType `json:"schemaType,omitempty"`
+}
+
+type schemaResponse struct {
+	ID int `json:"id"`
+}
+
+type errorResponse struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// Register registers the schema under the subject and returns its identifier. If the
+// schema is already registered, the identifier of the existing schema is returned.
+func (c *Client) Register(
+	ctx context.Context,
+	subject, schema string,
+	schemaType SchemaType,
+) (int, error) {
+	path := fmt.Sprintf("/subjects/%s/versions", url.PathEscape(subject))
+	return c.post(ctx, path, schema, schemaType)
+}
+
+// Lookup returns the identifier of the schema already registered under the subject.
+func (c *Client) Lookup(
+	ctx context.Context,
+	subject, schema string,
+	schemaType SchemaType,
+) (int, error) {
+	path := fmt.Sprintf("/subjects/%s", url.PathEscape(subject))
+	return c.post(ctx, path, schema, schemaType)
+}
+
+func (c *Client) post(
+	ctx context.Context,
+	path, schema string,
+	schemaType SchemaType,
+) (int, error) {
+	// The registry assumes Avro schema when the type is omitted, which keeps
+	// the compatibility with registries not supporting other schema types.
+	if schemaType == Avro {
+		schemaType = ""
+	}
+
+	body, err := json.Marshal(&schemaRequest{Schema: schema, SchemaType: schemaType})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal schema request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", contentType)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request to registry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read registry response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		if err = json.Unmarshal(data, &e); err == nil && e.Message != "" {
+			return 0, fmt.Errorf("registry returned error %d: %s", e.ErrorCode, e.Message)
+		}
+		return 0, fmt.Errorf("registry returned unexpected status: %s", resp.Status)
+	}
+
+	var s schemaResponse
+	if err = json.Unmarshal(data, &s); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal registry response: %w", err)
+	}
+	return s.ID, nil
+}
+
+// TopicSubject returns the subject of the message value schema for the topic,
+// following the default topic name strategy of the registry.
+func TopicSubject(topic string) string {
+	return topic + "-value"
+}
+
+// Frame prepends the payload with the header of the Confluent wire format, which
+// consists of the magic byte and the schema identifier.
+func Frame(id int, payload []byte) []byte {
+	framed := make([]byte, 5, 5+len(payload))
+	framed[0] = magicByte
+	binary.BigEndian.PutUint32(framed[1:], uint32(id))
+	return append(framed, payload...)
+}
+
+// FrameProtobuf frames the protobuf payload like Frame, additionally including the
+// message indexes, which point to the first message defined in the schema.
+func FrameProtobuf(id int, payload []byte) []byte {
+	// The list of message indexes consisting only of the first message is
+	// encoded as a single zero byte.
+	framed := Frame(id, []byte{0})
+	return append(framed, payload...)
+}
+
+// WithBasicAuth configures the client to authenticate using the username and password.
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithTimeout sets the timeout of requests sent to the registry.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.http.Timeout = timeout
+	}
+}
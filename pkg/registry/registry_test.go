@@ -0,0 +1,116 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/raczu/kube2kafka/pkg/registry"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		server   *httptest.Server
+		requests []*http.Request
+		bodies   []map[string]any
+		status   int
+		response string
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		bodies = nil
+		status = http.StatusOK
+		response = `{"id": 42}`
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			requests = append(requests, r)
+			bodies = append(bodies, body)
+
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(response))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	When("registering a schema", func() {
+		It("should post the schema to the subject versions and return its id", func() {
+			client := registry.NewClient(server.URL + "/")
+			id, err := client.Register(context.Background(), "events-value", "{}", registry.Avro)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(Equal(42))
+
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].Method).To(Equal(http.MethodPost))
+			Expect(requests[0].URL.Path).To(Equal("/subjects/events-value/versions"))
+			Expect(bodies[0]).To(Equal(map[string]any{"schema": "{}"}))
+		})
+
+		It("should include the schema type if it is not avro", func() {
+			client := registry.NewClient(server.URL)
+			_, err := client.Register(context.Background(), "events-value", "", registry.Protobuf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bodies[0]).To(HaveKeyWithValue("schemaType", "PROTOBUF"))
+		})
+
+		It("should authenticate using basic auth if configured", func() {
+			client := registry.NewClient(server.URL, registry.WithBasicAuth("user", "secret"))
+			_, err := client.Register(context.Background(), "events-value", "{}", registry.Avro)
+			Expect(err).NotTo(HaveOccurred())
+
+			username, password, ok := requests[0].BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(username).To(Equal("user"))
+			Expect(password).To(Equal("secret"))
+		})
+
+		It("should return an error with the registry message on failure", func() {
+			status = http.StatusConflict
+			response = `{"error_code": 409, "message": "incompatible schema"}`
+
+			client := registry.NewClient(server.URL)
+			_, err := client.Register(context.Background(), "events-value", "{}", registry.Avro)
+			Expect(err).To(MatchError(ContainSubstring("incompatible schema")))
+		})
+	})
+
+	When("looking up a schema", func() {
+		It("should post the schema to the subject and return its id", func() {
+			client := registry.NewClient(server.URL)
+			id, err := client.Lookup(context.Background(), "events-value", "{}", registry.Avro)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(Equal(42))
+			Expect(requests[0].URL.Path).To(Equal("/subjects/events-value"))
+		})
+
+		It("should return an error if schema is not registered", func() {
+			status = http.StatusNotFound
+			response = `{"error_code": 40403, "message": "Schema not found"}`
+
+			client := registry.NewClient(server.URL)
+			_, err := client.Lookup(context.Background(), "events-value", "{}", registry.Avro)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("Frame", func() {
+	It("should prepend the payload with magic byte and schema id", func() {
+		framed := registry.Frame(258, []byte{0xAA, 0xBB})
+		Expect(framed).To(Equal([]byte{0x00, 0x00, 0x00, 0x01, 0x02, 0xAA, 0xBB}))
+	})
+})
+
+var _ = Describe("FrameProtobuf", func() {
+	It("should include the index of the first message after schema id", func() {
+		framed := registry.FrameProtobuf(1, []byte{0xAA})
+		Expect(framed).To(Equal([]byte{0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0xAA}))
+	})
+})
@@ -0,0 +1,181 @@
+package spool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// headerSize is the size of the record header, i.e. the length and the CRC
+// of the record payload.
+const headerSize = 8
+
+// maxPayloadSize is the upper bound of the payload size, larger sizes can only
+// come from the corrupted header.
+const maxPayloadSize = 1 << 28
+
+var errCorrupted = errors.New("record is corrupted")
+
+// appendBytes appends the length-prefixed bytes. The length is shifted by one, so the nil
+// bytes can be told apart from the empty ones, e.g. the message without the key.
+func appendBytes(b, data []byte) []byte {
+	if data == nil {
+		return binary.AppendUvarint(b, 0)
+	}
+	b = binary.AppendUvarint(b, uint64(len(data))+1)
+	return append(b, data...)
+}
+
+// encodeRecord encodes the message as the record consisting of the header and the payload.
+func encodeRecord(message *kafka.Message) []byte {
+	payload := appendBytes(nil, []byte(message.Topic))
+	payload = appendBytes(payload, message.Key)
+	payload = appendBytes(payload, message.Value)
+	payload = binary.AppendUvarint(payload, uint64(len(message.Headers)))
+	for _, header := range message.Headers {
+		payload = appendBytes(payload, []byte(header.Key))
+		payload = appendBytes(payload, header.Value)
+	}
+
+	var timestamp int64
+	if !message.Time.IsZero() {
+		timestamp = message.Time.UnixNano()
+	}
+	payload = binary.AppendVarint(payload, timestamp)
+	// The partition is appended last, so the records written without it remain readable.
+	payload = binary.AppendVarint(payload, int64(message.Partition))
+
+	record := make([]byte, headerSize, headerSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	return append(record, payload...)
+}
+
+// decoder decodes the fields of the record payload.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errCorrupted
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = errCorrupted
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil || n == 0 {
+		return nil
+	}
+
+	n--
+	if n > uint64(len(d.b)) {
+		d.err = errCorrupted
+		return nil
+	}
+	data := d.b[:n:n]
+	d.b = d.b[n:]
+	return data
+}
+
+// decodePayload decodes the message from the record payload.
+func decodePayload(payload []byte) (*kafka.Message, error) {
+	d := &decoder{b: payload}
+	message := &kafka.Message{
+		Topic: string(d.bytes()),
+		Key:   d.bytes(),
+		Value: d.bytes(),
+	}
+
+	count := d.uvarint()
+	if count > uint64(len(d.b)) {
+		return nil, errCorrupted
+	}
+
+	for i := uint64(0); i < count; i++ {
+		message.Headers = append(message.Headers, kafka.Header{
+			Key:   string(d.bytes()),
+			Value: d.bytes(),
+		})
+	}
+
+	if timestamp := d.varint(); timestamp != 0 {
+		message.Time = time.Unix(0, timestamp)
+	}
+
+	if len(d.b) > 0 {
+		message.Partition = int(d.varint())
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return message, nil
+}
+
+// readRecord reads the next record from the reader and returns the decoded message along
+// with the size of the record. It returns io.EOF if there are no more records and
+// errCorrupted if the record is incomplete or does not match its CRC.
+func readRecord(r io.Reader) (*kafka.Message, int, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, io.EOF
+		}
+
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errCorrupted
+		}
+		return nil, 0, err
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > maxPayloadSize {
+		return nil, 0, errCorrupted
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errCorrupted
+		}
+		return nil, 0, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, errCorrupted
+	}
+
+	message, err := decodePayload(payload)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode record: %w", err)
+	}
+	return message, headerSize + len(payload), nil
+}
@@ -0,0 +1,685 @@
+package spool
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	log "github.com/raczu/kube2kafka/pkg/logger"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSegmentBytes is the default size of the segment, after which the next
+	// appended messages are written to the new segment.
+	DefaultSegmentBytes = 16 * 1024 * 1024
+	segmentExt          = ".seg"
+	ackFile             = "ack"
+	// maxRetentionInterval is the longest interval between the checks of the age
+	// limit, which are needed when no messages are appended for a long time.
+	maxRetentionInterval = time.Minute
+)
+
+// closed is returned to the readers waiting for the messages when the spool has
+// some messages to read.
+var closed = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// Entry is the message read from the spool along with its offset, which is used
+// to acknowledge the message.
+type Entry struct {
+	Offset  uint64
+	Message *kafka.Message
+}
+
+// segment is the file holding the records of the consecutive messages, where base
+// is the offset of the first one.
+type segment struct {
+	base    uint64
+	count   uint64
+	size    int64
+	modTime time.Time
+	path    string
+}
+
+// end returns the offset following the last message in the segment.
+func (s *segment) end() uint64 {
+	return s.base + s.count
+}
+
+// cursor is the position of the next message to read.
+type cursor struct {
+	offset uint64
+	// seg is the segment the pos refers to. If it does not hold the offset,
+	// the position has to be located again.
+	seg *segment
+	pos int64
+}
+
+type Option func(*Spool)
+
+// Spool is the persistent, segment-based write-ahead log of the messages. Messages are
+// read in the order they were appended and remain in the spool until acknowledged, so
+// they survive the restarts and can be read again if not acknowledged.
+type Spool struct {
+	dir          string
+	segmentBytes int64
+	maxBytes     int64
+	maxAge       time.Duration
+	logger       *zap.Logger
+
+	mu       sync.Mutex
+	segments []*segment
+	active   *os.File
+	// acked is the offset of the first message not acknowledged yet.
+	acked   uint64
+	read    cursor
+	lost    uint64
+	notify  chan struct{}
+	waiting bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open opens the spool in the directory, creating it if needed. The messages which were
+// not acknowledged before are recovered, whereas the incomplete or corrupted records left
+// by the crash are truncated.
+func Open(dir string, opts ...Option) (*Spool, error) {
+	s := &Spool{
+		dir:          dir,
+		segmentBytes: DefaultSegmentBytes,
+		logger:       log.New().Named("spool"),
+		notify:       make(chan struct{}),
+		stop:         make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+
+	if err := s.enforceRetention(); err != nil {
+		s.active.Close()
+		return nil, err
+	}
+
+	if s.maxAge > 0 {
+		s.wg.Add(1)
+		go s.expire()
+	}
+	return s, nil
+}
+
+// expire periodically removes the segments exceeding the age limit, so they are
+// removed even if no messages are appended, e.g. during the outage of Kafka.
+func (s *Spool) expire() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(min(s.maxAge/2, maxRetentionInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if err := s.enforceRetention(); err != nil {
+				// The retention is enforced again on the next tick or append.
+				s.logger.Warn("failed to enforce spool retention", zap.Error(err))
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Spool) segmentPath(base uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", base, segmentExt))
+}
+
+// recover loads the segments and the acknowledged offset from the directory.
+func (s *Spool) recover() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+
+		base, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		seg, err := s.loadSegment(base, filepath.Join(s.dir, name))
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, seg)
+	}
+
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].base < s.segments[j].base
+	})
+
+	acked, err := s.readAck()
+	if err != nil {
+		return err
+	}
+
+	if len(s.segments) == 0 {
+		if err = s.createSegment(acked); err != nil {
+			return err
+		}
+	} else {
+		s.active, err = os.OpenFile(s.last().path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open spool segment: %w", err)
+		}
+	}
+
+	s.acked = min(max(acked, s.segments[0].base), s.last().end())
+	s.read = cursor{offset: s.acked}
+	s.removeAcked()
+
+	s.logger.Info("spool recovered",
+		zap.String("dir", s.dir),
+		zap.Int("segments", len(s.segments)),
+		zap.Uint64("pending", s.last().end()-s.acked),
+	)
+	return nil
+}
+
+// loadSegment counts the records in the segment file, truncating it at the first
+// incomplete or corrupted record.
+func (s *Spool) loadSegment(base uint64, path string) (*segment, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	seg := &segment{base: base, path: path}
+	reader := bufio.NewReader(file)
+	for {
+		_, n, err := readRecord(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if errors.Is(err, errCorrupted) {
+			s.logger.Warn("truncating corrupted spool segment",
+				zap.String("segment", path),
+				zap.Int64("offset", seg.size),
+			)
+
+			if err = file.Truncate(seg.size); err != nil {
+				return nil, fmt.Errorf("failed to truncate spool segment: %w", err)
+			}
+
+			if err = file.Sync(); err != nil {
+				return nil, fmt.Errorf("failed to sync spool segment: %w", err)
+			}
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spool segment: %w", err)
+		}
+		seg.count++
+		seg.size += int64(n)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat spool segment: %w", err)
+	}
+	seg.modTime = info.ModTime()
+	return seg, nil
+}
+
+func (s *Spool) readAck() (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, ackFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read spool ack: %w", err)
+	}
+
+	acked, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse spool ack: %w", err)
+	}
+	return acked, nil
+}
+
+// writeAck persists the acknowledged offset. The file is replaced atomically, so it is
+// never left partially written.
+func (s *Spool) writeAck() error {
+	path := filepath.Join(s.dir, ackFile)
+	tmp := path + ".tmp"
+
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to create spool ack: %w", err)
+	}
+
+	_, err = file.WriteString(strconv.FormatUint(s.acked, 10))
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write spool ack: %w", err)
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace spool ack: %w", err)
+	}
+	return s.syncDir()
+}
+
+func (s *Spool) syncDir() error {
+	dir, err := os.Open(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to open spool directory: %w", err)
+	}
+	defer dir.Close()
+
+	if err = dir.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool directory: %w", err)
+	}
+	return nil
+}
+
+func (s *Spool) last() *segment {
+	return s.segments[len(s.segments)-1]
+}
+
+// createSegment creates the new active segment starting at the base offset.
+func (s *Spool) createSegment(base uint64) error {
+	path := s.segmentPath(base)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to create spool segment: %w", err)
+	}
+
+	s.active = file
+	s.segments = append(s.segments, &segment{base: base, path: path, modTime: time.Now()})
+	return s.syncDir()
+}
+
+// roll seals the active segment and creates the next one.
+func (s *Spool) roll() error {
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+
+	if err := s.active.Close(); err != nil {
+		return fmt.Errorf("failed to close spool segment: %w", err)
+	}
+	return s.createSegment(s.last().end())
+}
+
+// flush writes the encoded records to the active segment.
+func (s *Spool) flush(records []byte, count uint64) error {
+	if count == 0 {
+		return nil
+	}
+
+	if _, err := s.active.Write(records); err != nil {
+		return fmt.Errorf("failed to write to spool segment: %w", err)
+	}
+
+	seg := s.last()
+	seg.count += count
+	seg.size += int64(len(records))
+	seg.modTime = time.Now()
+	return nil
+}
+
+// Append writes the messages to the spool. The messages are synced to the disk
+// before it returns, so they are not lost on the crash.
+func (s *Spool) Append(messages ...*kafka.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		records []byte
+		count   uint64
+	)
+
+	for _, message := range messages {
+		record := encodeRecord(message)
+		size := s.last().size + int64(len(records))
+		if size > 0 && size+int64(len(record)) > s.segmentBytes {
+			if err := s.flush(records, count); err != nil {
+				return err
+			}
+			records, count = records[:0], 0
+
+			if err := s.roll(); err != nil {
+				return err
+			}
+		}
+		records = append(records, record...)
+		count++
+	}
+
+	if err := s.flush(records, count); err != nil {
+		return err
+	}
+
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+
+	if err := s.enforceRetention(); err != nil {
+		return err
+	}
+
+	if s.waiting && s.read.offset < s.last().end() {
+		close(s.notify)
+		s.notify = make(chan struct{})
+		s.waiting = false
+	}
+	return nil
+}
+
+// enforceRetention removes the oldest segments exceeding the size or age limits, even
+// if their messages are not acknowledged. The active segment is never removed.
+func (s *Spool) enforceRetention() error {
+	var size int64
+	for _, seg := range s.segments {
+		size += seg.size
+	}
+
+	for len(s.segments) > 1 {
+		seg := s.segments[0]
+		exceeded := s.maxBytes > 0 && size > s.maxBytes
+		expired := s.maxAge > 0 && time.Since(seg.modTime) > s.maxAge
+		if !exceeded && !expired {
+			break
+		}
+
+		if seg.end() > s.acked {
+			lost := seg.end() - max(s.acked, seg.base)
+			s.lost += lost
+			s.logger.Warn("removing spool segment with unacknowledged messages",
+				zap.String("segment", seg.path),
+				zap.Uint64("lost", lost),
+				zap.Bool("exceeded", exceeded),
+				zap.Bool("expired", expired),
+			)
+
+			s.acked = seg.end()
+			if err := s.writeAck(); err != nil {
+				return err
+			}
+		}
+
+		if s.read.offset < seg.end() {
+			s.read = cursor{offset: seg.end()}
+		}
+
+		size -= seg.size
+		if err := s.removeSegment(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeAcked removes the oldest segments whose messages were all acknowledged.
+func (s *Spool) removeAcked() {
+	for len(s.segments) > 1 && s.segments[0].end() <= s.acked {
+		if err := s.removeSegment(); err != nil {
+			// The segment is removed again on the next acknowledgement.
+			s.logger.Warn("failed to remove acknowledged spool segment", zap.Error(err))
+			return
+		}
+	}
+}
+
+// removeSegment removes the oldest segment, which must not be the active one.
+func (s *Spool) removeSegment() error {
+	seg := s.segments[0]
+	if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove spool segment: %w", err)
+	}
+	s.segments = s.segments[1:]
+	return nil
+}
+
+// find returns the segment holding the message with the offset. If the offset is not
+// held by any segment, e.g. it was lost due to the corruption, the next segment is
+// returned, whereas nil is returned if there is no such segment.
+func (s *Spool) find(offset uint64) *segment {
+	for _, seg := range s.segments {
+		if offset < seg.end() {
+			return seg
+		}
+	}
+	return nil
+}
+
+// locate updates the read cursor to point at the position of its offset.
+func (s *Spool) locate() (*segment, error) {
+	seg := s.find(s.read.offset)
+	if seg == nil || seg == s.read.seg {
+		return seg, nil
+	}
+
+	if s.read.offset < seg.base {
+		s.lost += seg.base - s.read.offset
+		s.read.offset = seg.base
+	}
+
+	file, err := os.Open(seg.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	var pos int64
+	reader := bufio.NewReader(file)
+	for i := seg.base; i < s.read.offset; i++ {
+		_, n, err := readRecord(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spool segment: %w", err)
+		}
+		pos += int64(n)
+	}
+
+	s.read.seg = seg
+	s.read.pos = pos
+	return seg, nil
+}
+
+// Read returns up to n next messages from the spool. The messages are read only once,
+// unless the spool is rewound.
+func (s *Spool) Read(n int) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []Entry
+	for len(entries) < n {
+		seg, err := s.locate()
+		if err != nil {
+			return entries, err
+		}
+
+		if seg == nil {
+			break
+		}
+
+		entries, err = s.readSegment(seg, entries, n)
+		if err != nil {
+			return entries, err
+		}
+	}
+	return entries, nil
+}
+
+// readSegment reads the messages from the segment at the read cursor until the n
+// entries are read or the segment ends.
+func (s *Spool) readSegment(seg *segment, entries []Entry, n int) ([]Entry, error) {
+	file, err := os.Open(seg.path)
+	if err != nil {
+		return entries, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	if _, err = file.Seek(s.read.pos, io.SeekStart); err != nil {
+		return entries, fmt.Errorf("failed to seek spool segment: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+	for len(entries) < n && s.read.offset < seg.end() {
+		message, size, err := readRecord(reader)
+		if err != nil {
+			return entries, fmt.Errorf("failed to read spool segment: %w", err)
+		}
+
+		entries = append(entries, Entry{Offset: s.read.offset, Message: message})
+		s.read.offset++
+		s.read.pos += int64(size)
+	}
+	return entries, nil
+}
+
+// Ack acknowledges the message with the offset along with all messages preceding it,
+// so they are not read again after the restart or rewind.
+func (s *Spool) Ack(offset uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if offset < s.acked || offset >= s.last().end() {
+		return nil
+	}
+
+	s.acked = offset + 1
+	if err := s.writeAck(); err != nil {
+		return err
+	}
+	s.removeAcked()
+	return nil
+}
+
+// Rewind moves back the read cursor to the first message not acknowledged yet.
+func (s *Spool) Rewind() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.read = cursor{offset: s.acked}
+}
+
+// NotEmpty returns a channel that is closed as soon as the spool holds any message
+// to read. If there is such message, the returned channel is already closed.
+func (s *Spool) NotEmpty() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.read.offset < s.last().end() {
+		return closed
+	}
+
+	s.waiting = true
+	return s.notify
+}
+
+// Pending returns the number of messages not acknowledged yet.
+func (s *Spool) Pending() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last().end() - s.acked
+}
+
+// Bytes returns the total size of the segments.
+func (s *Spool) Bytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var size int64
+	for _, seg := range s.segments {
+		size += seg.size
+	}
+	return size
+}
+
+// Lost returns the number of messages removed from the spool before they were
+// acknowledged, either due to the retention limits or the corruption.
+func (s *Spool) Lost() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lost
+}
+
+// Close stops the retention checks, then syncs and closes the active segment.
+func (s *Spool) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+	return s.active.Close()
+}
+
+// WithSegmentBytes sets the size of the segment, after which the new one is created.
+// Messages are removed only along with their segment, thus it defines the granularity
+// of the retention.
+func WithSegmentBytes(n int64) Option {
+	return func(s *Spool) {
+		s.segmentBytes = n
+	}
+}
+
+// WithRetention sets the limits of the total size and the age of the segments. When any
+// of them is exceeded, the oldest segments are removed even if their messages are not
+// acknowledged. The age limit is checked periodically too, so the segments expire even
+// if no messages are appended. Limits which are not positive are not applied.
+func WithRetention(maxBytes int64, maxAge time.Duration) Option {
+	return func(s *Spool) {
+		s.maxBytes = maxBytes
+		s.maxAge = maxAge
+	}
+}
+
+// WithLogger sets the logger for the spool.
+func WithLogger(logger *zap.Logger) Option {
+	return func(s *Spool) {
+		s.logger = logger
+	}
+}
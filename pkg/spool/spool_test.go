@@ -0,0 +1,237 @@
+package spool_test
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	log "github.com/raczu/kube2kafka/pkg/logger"
+	"github.com/raczu/kube2kafka/pkg/spool"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var _ = Describe("Spool", func() {
+	var (
+		dir    string
+		s      *spool.Spool
+		logger *zap.Logger
+	)
+
+	message := func(i int) *kafka.Message {
+		return &kafka.Message{
+			Key:   []byte(fmt.Sprintf("key-%d", i)),
+			Value: []byte(fmt.Sprintf("value-%d", i)),
+		}
+	}
+
+	open := func(opts ...spool.Option) *spool.Spool {
+		opts = append(opts, spool.WithLogger(logger))
+		s, err := spool.Open(dir, opts...)
+		Expect(err).NotTo(HaveOccurred())
+		return s
+	}
+
+	values := func(entries []spool.Entry) []string {
+		var values []string
+		for _, entry := range entries {
+			values = append(values, string(entry.Message.Value))
+		}
+		return values
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		opts := log.Options{Output: &bytes.Buffer{}}
+		logger = log.New(log.UseOptions(&opts))
+		s = open()
+	})
+
+	AfterEach(func() {
+		Expect(s.Close()).To(Succeed())
+	})
+
+	When("appending and reading messages", func() {
+		It("should read the messages in the order they were appended", func() {
+			Expect(s.Append(message(0), message(1))).To(Succeed())
+			Expect(s.Append(message(2))).To(Succeed())
+
+			entries, err := s.Read(2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values(entries)).To(Equal([]string{"value-0", "value-1"}))
+			Expect(entries[1].Offset).To(Equal(uint64(1)))
+
+			entries, err = s.Read(10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values(entries)).To(Equal([]string{"value-2"}))
+
+			entries, err = s.Read(10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+
+		It("should preserve all fields of the message", func() {
+			original := &kafka.Message{
+				Topic:     "events",
+				Value:     []byte("value"),
+				Headers:   []kafka.Header{{Key: "cluster", Value: []byte("dev")}},
+				Time:      time.Unix(1700000000, 123),
+				Partition: 3,
+			}
+			Expect(s.Append(original)).To(Succeed())
+
+			entries, err := s.Read(1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries[0].Message.Topic).To(Equal(original.Topic))
+			Expect(entries[0].Message.Key).To(BeNil())
+			Expect(entries[0].Message.Headers).To(Equal(original.Headers))
+			Expect(entries[0].Message.Time.Equal(original.Time)).To(BeTrue())
+			Expect(entries[0].Message.Partition).To(Equal(original.Partition))
+		})
+
+		It("should notify the reader about the appended messages", func() {
+			ch := s.NotEmpty()
+			Expect(ch).NotTo(BeClosed())
+
+			Expect(s.Append(message(0))).To(Succeed())
+			Expect(ch).To(BeClosed())
+			Expect(s.NotEmpty()).To(BeClosed())
+		})
+	})
+
+	When("acknowledging messages", func() {
+		BeforeEach(func() {
+			for i := 0; i < 3; i++ {
+				Expect(s.Append(message(i))).To(Succeed())
+			}
+		})
+
+		It("should read the unacknowledged messages again after rewind", func() {
+			entries, err := s.Read(3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.Ack(entries[0].Offset)).To(Succeed())
+
+			s.Rewind()
+			entries, err = s.Read(3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values(entries)).To(Equal([]string{"value-1", "value-2"}))
+			Expect(s.Pending()).To(Equal(uint64(2)))
+		})
+
+		It("should recover the unacknowledged messages after reopening", func() {
+			Expect(s.Ack(0)).To(Succeed())
+			Expect(s.Close()).To(Succeed())
+
+			s = open()
+			Expect(s.Pending()).To(Equal(uint64(2)))
+			entries, err := s.Read(3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values(entries)).To(Equal([]string{"value-1", "value-2"}))
+
+			Expect(s.Append(message(3))).To(Succeed())
+			entries, err = s.Read(1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries[0].Offset).To(Equal(uint64(3)))
+		})
+
+		It("should truncate the incomplete record left by the crash", func() {
+			Expect(s.Close()).To(Succeed())
+			segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(segments).To(HaveLen(1))
+
+			file, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = file.Write([]byte{0, 0, 0, 42, 1, 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+
+			s = open()
+			Expect(s.Pending()).To(Equal(uint64(3)))
+			Expect(s.Append(message(3))).To(Succeed())
+
+			entries, err := s.Read(10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values(entries)).To(Equal([]string{"value-0", "value-1", "value-2", "value-3"}))
+		})
+	})
+
+	When("the messages span multiple segments", func() {
+		BeforeEach(func() {
+			Expect(s.Close()).To(Succeed())
+			// Each segment holds a single message.
+			s = open(spool.WithSegmentBytes(1), spool.WithRetention(0, 0))
+			for i := 0; i < 4; i++ {
+				Expect(s.Append(message(i))).To(Succeed())
+			}
+		})
+
+		segments := func() []string {
+			segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+			Expect(err).NotTo(HaveOccurred())
+			return segments
+		}
+
+		It("should read the messages across the segments", func() {
+			Expect(segments()).To(HaveLen(4))
+			entries, err := s.Read(10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values(entries)).To(HaveLen(4))
+		})
+
+		It("should remove the acknowledged segments", func() {
+			Expect(s.Ack(1)).To(Succeed())
+			Expect(segments()).To(HaveLen(2))
+
+			s.Rewind()
+			entries, err := s.Read(10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values(entries)).To(Equal([]string{"value-2", "value-3"}))
+		})
+
+		It("should remove the oldest segments exceeding the retention", func() {
+			size := s.Bytes() / 4
+			Expect(s.Close()).To(Succeed())
+
+			s = open(spool.WithSegmentBytes(1), spool.WithRetention(2*size, 0))
+			Expect(s.Append(message(4))).To(Succeed())
+			Expect(s.Lost()).To(Equal(uint64(3)))
+			Expect(s.Pending()).To(Equal(uint64(2)))
+
+			entries, err := s.Read(10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values(entries)).To(Equal([]string{"value-3", "value-4"}))
+		})
+
+		It("should remove the expired segments without further appends", func() {
+			Expect(s.Close()).To(Succeed())
+
+			s = open(spool.WithSegmentBytes(1), spool.WithRetention(0, 200*time.Millisecond))
+			Expect(segments()).To(HaveLen(4))
+
+			Eventually(segments).WithTimeout(2 * time.Second).Should(HaveLen(1))
+			Expect(s.Lost()).To(Equal(uint64(3)))
+			Expect(s.Pending()).To(Equal(uint64(1)))
+
+			entries, err := s.Read(10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values(entries)).To(Equal([]string{"value-3"}))
+		})
+
+		It("should remove the expired segments when opened", func() {
+			Expect(s.Close()).To(Succeed())
+
+			expired := time.Now().Add(-time.Hour)
+			for _, segment := range segments()[:3] {
+				Expect(os.Chtimes(segment, expired, expired)).To(Succeed())
+			}
+
+			s = open(spool.WithSegmentBytes(1), spool.WithRetention(0, time.Minute))
+			Expect(segments()).To(HaveLen(1))
+			Expect(s.Lost()).To(Equal(uint64(3)))
+		})
+	})
+})